@@ -21,7 +21,7 @@ var (
 	allCmd = &cobra.Command{
 		Use:   "all",
 		Short: "Run the complete mailslurper service.",
-		Long:  `Run the complete mailslurper service.`,
+		Long:  `Run the complete mailslurper service. Pending database migrations are applied on start.`,
 		Run: func(cmd *cobra.Command, _ []string) {
 			vpr := viper.New()
 
@@ -50,16 +50,24 @@ var (
 
 			orm, err := persistence.NewORM(config.Database, xss, logger)
 			cobra.CheckErr(err)
+			// the schema is brought up to date before either service touches the database
+			cobra.CheckErr(orm.MigrateUp())
+
+			smtpService := app.NewSMTPService(&config, xss, orm, logger)
 
 			appConfig := &app.HTTPServiceConfig{
 				Version:  cmd.Version,
 				Data:     orm,
+				Faults:   smtpService.Faults(),
+				Mail:     smtpService.Mail(),
+				Events:   smtpService.Events(),
 				Config:   &config,
+				XSS:      xss,
 				Renderer: renderer,
 				Logger:   logger,
 			}
 			cobra.CheckErr(mgr.Add(app.NewHTTPService(appConfig)))
-			cobra.CheckErr(mgr.Add(app.NewSMTPService(&config, xss, orm, logger)))
+			cobra.CheckErr(mgr.Add(smtpService))
 
 			ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
 			defer cancel()
@@ -26,7 +26,7 @@ var (
 	httpCmd = &cobra.Command{
 		Use:   "http",
 		Short: "Run the http mailslurper service.",
-		Long:  `Run the http mailslurper service.`,
+		Long:  `Run the http mailslurper service. Pending database migrations are applied on start.`,
 		Run: func(cmd *cobra.Command, _ []string) {
 			vpr := viper.New()
 
@@ -49,6 +49,8 @@ var (
 
 			orm, err := persistence.NewORM(config.Database, xss, logger)
 			cobra.CheckErr(err)
+			// the schema is brought up to date before the service touches the database
+			cobra.CheckErr(orm.MigrateUp())
 
 			mgr := service.NewRecoverableServiceManager(
 				service.WithRecoverWait(5*time.Second),
@@ -60,6 +62,7 @@ var (
 				Version:  cmd.Version,
 				Data:     orm,
 				Config:   &config,
+				XSS:      xss,
 				Renderer: renderer,
 				Logger:   logger,
 			}
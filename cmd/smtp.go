@@ -25,7 +25,7 @@ var (
 	smtpCmd = &cobra.Command{
 		Use:   "smtp",
 		Short: "Run the smtp mailslurper service.",
-		Long:  `Run the smtp mailslurper service.`,
+		Long:  `Run the smtp mailslurper service. Pending database migrations are applied on start.`,
 		Run: func(cmd *cobra.Command, _ []string) {
 			vpr := viper.New()
 
@@ -46,6 +46,8 @@ var (
 			logger.Debug("Starting MailSlurper SMTP Service", "version", "v"+cmd.Version)
 			orm, err := persistence.NewORM(config.Database, xss, logger)
 			cobra.CheckErr(err)
+			// the schema is brought up to date before the service touches the database
+			cobra.CheckErr(orm.MigrateUp())
 
 			mgr := service.NewRecoverableServiceManager(
 				service.WithRecoverWait(5*time.Second),
@@ -7,10 +7,14 @@ package app
 import (
 	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
 
+// broadcastBufferSize is the number of mail items or events a subscriber may fall behind before it misses any.
+const broadcastBufferSize = 100
+
 // MailWriter stores a mail item to a persistance layer.
 type MailWriter interface {
 	StoreMail(mailItem *model.MailItem) error
@@ -42,3 +46,88 @@ func (r DatabaseReceiver) Receive(mailItem *model.MailItem) error {
 
 	return nil
 }
+
+// BroadcastReceiver passes each mail item on to everyone subscribed at the time it arrives, such as API requests
+// waiting for a mail. It is only handed mail once the DatabaseReceiver has stored it.
+type BroadcastReceiver struct {
+	broadcaster[*model.MailItem]
+}
+
+// NewBroadcastReceiver creates a new BroadcastReceiver object.
+func NewBroadcastReceiver() *BroadcastReceiver {
+	return &BroadcastReceiver{
+		broadcaster: broadcaster[*model.MailItem]{
+			subscribers: make(map[chan *model.MailItem]struct{}),
+		},
+	}
+}
+
+// Receive passes the mail item on to every subscriber.
+func (r *BroadcastReceiver) Receive(mailItem *model.MailItem) error {
+	r.publish(mailItem)
+
+	return nil
+}
+
+// EventReceiver pushes mail events to everyone following along, such as the UI and dashboards streaming events from the
+// API. Received mail comes in as a receiver once the DatabaseReceiver has stored it, while deletes are published by the
+// API.
+type EventReceiver struct {
+	broadcaster[model.MailEvent]
+}
+
+// NewEventReceiver creates a new EventReceiver object.
+func NewEventReceiver() *EventReceiver {
+	return &EventReceiver{
+		broadcaster: broadcaster[model.MailEvent]{
+			subscribers: make(map[chan model.MailEvent]struct{}),
+		},
+	}
+}
+
+// Receive publishes a mail.received event for the mail item.
+func (r *EventReceiver) Receive(mailItem *model.MailItem) error {
+	r.publish(model.NewMailReceivedEvent(mailItem))
+
+	return nil
+}
+
+// Publish passes an event on to every subscriber.
+func (r *EventReceiver) Publish(event model.MailEvent) {
+	r.publish(event)
+}
+
+// broadcaster passes values on to everyone subscribed at the time they are published.
+type broadcaster[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+}
+
+// publish passes the value on to every subscriber. A subscriber that is not keeping up misses the value rather than
+// holding up the others.
+func (b *broadcaster[T]) publish(value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- value:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every value from now on, along with a function to end the subscription.
+func (b *broadcaster[T]) Subscribe() (<-chan T, func()) {
+	subscriber := make(chan T, broadcastBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	return subscriber, func() {
+		b.mu.Lock()
+		delete(b.subscribers, subscriber)
+		b.mu.Unlock()
+	}
+}
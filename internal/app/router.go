@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/adampresley/webframework/sanitizer"
 	"github.com/go-chi/chi"
 
 	"github.com/mailslurper/mailslurper/v2/internal/handlers"
@@ -24,12 +25,19 @@ type Persistance interface {
 	handlers.MailCollectionGetter
 	middleware.MailGetter
 	handlers.MailMessageRawGetter
+	handlers.MailPartTreeGetter
+	middleware.MailPartGetter
+	middleware.MailAttachmentGetter
 }
 
 type APIRouter struct {
 	Version    string
 	Data       Persistance
+	Faults     handlers.FaultRuleStore
+	Mail       handlers.MailSubscriber
+	Events     handlers.MailEvents
 	Config     *io.Config
+	XSS        sanitizer.IXSSServiceProvider
 	JWTService *jwt.JWTService
 	Logger     *log.Logger
 }
@@ -48,16 +56,42 @@ func (r *APIRouter) Routes() http.Handler {
 	router.Get("/pruneoptions", handlers.GetPruneOptions(r.Logger))
 	router.Get("/mailcount", handlers.GetMailCount(r.Data, r.Logger))
 
+	// fault injection is only available when the SMTP server runs in the same process
+	if r.Faults != nil {
+		router.Route("/faults", r.FaultRoutes())
+	}
+
+	// mail events are only available when the SMTP server runs in the same process
+	if r.Events != nil {
+		router.Get("/events", handlers.StreamMailEvents(r.Events, r.Logger))
+	}
+
 	// setup mail routes
 	router.Route("/mail", r.MailRoutes())
 
 	return router
 }
 
+func (r *APIRouter) FaultRoutes() func(chi.Router) {
+	return func(router chi.Router) {
+		router.Get("/", handlers.GetFaultRules(r.Faults, r.Logger))
+		router.Put("/", handlers.SetFaultRules(r.Faults, r.Logger))
+		router.Delete("/", handlers.ClearFaultRules(r.Faults, r.Logger))
+	}
+}
+
 func (r *APIRouter) MailRoutes() func(chi.Router) {
 	return func(router chi.Router) {
-		router.Get("/", handlers.GetMailCollection(r.Data, r.Logger)) // bulk get
-		router.Delete("/", handlers.DeleteMail(r.Data, r.Logger))     // bulk delete
+		router.Get("/", handlers.GetMailCollection(r.Data, r.Config.GetPageSize(), r.Logger)) // bulk get
+		router.Delete("/", handlers.DeleteMail(r.Data, r.Events, r.Logger))                   // bulk delete
+
+		// waiting for mail is only available when the SMTP server runs in the same process. The route is answered either
+		// way, so that it is not taken for a mail ID.
+		if r.Mail != nil {
+			router.Get("/wait", handlers.WaitForMail(r.Data, r.Mail, r.Logger))
+		} else {
+			router.Get("/wait", handlers.WaitForMailUnavailable(r.Logger))
+		}
 
 		router.Route(fmt.Sprintf("/{%s}", requests.MailIDPathParam), r.MailSubRoutes())
 	}
@@ -68,10 +102,25 @@ func (r *APIRouter) MailSubRoutes() func(chi.Router) {
 		router.Use(middleware.MailCtx(r.Data, chi.URLParam, r.Logger))
 
 		router.Get("/", handlers.GetMail(r.Data, r.Logger))
-		router.Get("/message", handlers.GetMailMessage(r.Logger))
+		router.Delete("/", handlers.DeleteMailItem(r.Data, r.Events, r.Logger))
+		router.Get("/message", handlers.GetMailMessage(r.XSS, r.Logger))
+		router.Get("/text", handlers.GetMailText(r.Logger))
+		router.Get("/html", handlers.GetMailHTML(r.Logger))
+		router.Get("/preview", handlers.PreviewMail(r.Logger))
 		router.Get("/messageraw", handlers.GetMailMessageRaw(r.Data, r.Logger))
+		router.Get("/raw.eml", handlers.DownloadMailMessageRaw(r.Data, r.Logger))
 
 		router.Route("/attachment", r.MailDetailSubRoutes())
+		router.Route("/parts", r.MailPartRoutes())
+	}
+}
+
+func (r *APIRouter) MailPartRoutes() func(chi.Router) {
+	return func(router chi.Router) {
+		router.Get("/", handlers.GetMailParts(r.Data, r.Logger))
+
+		router.With(middleware.MailPartCtx(r.Data, chi.URLParam, r.Logger)).
+			Get(fmt.Sprintf("/{%s}", requests.MailPartIDPathParam), handlers.DownloadMailPart(r.Logger))
 	}
 }
 
@@ -83,6 +132,8 @@ func (r *APIRouter) MailDetailSubRoutes() func(chi.Router) {
 
 func (r *APIRouter) MailAttachmentSubRoutes() func(chi.Router) {
 	return func(router chi.Router) {
+		router.Use(middleware.MailAttachmentCtx(r.Data, chi.URLParam, r.Logger))
+
 		router.Get("/", handlers.DownloadAttachment(r.Logger))
 	}
 }
@@ -0,0 +1,34 @@
+package app_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/app"
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/internal/mocks"
+	"github.com/mailslurper/mailslurper/v2/pkg/auth/authscheme"
+)
+
+func TestAPIRouter_WaitWithoutSMTP(t *testing.T) {
+	t.Parallel()
+
+	router := &app.APIRouter{
+		Data:   new(mocks.MockPersistance),
+		Config: &slurperio.Config{AuthenticationScheme: authscheme.NONE},
+		Logger: slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug),
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/mail/wait?to=alice@x.test", nil)
+
+	router.Routes().ServeHTTP(recorder, request)
+
+	// the route is not taken for a mail ID, which would be answered with 400
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "response code should match expected")
+	assert.Contains(t, recorder.Body.String(), "SMTP server")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/easterthebunny/service"
 	"github.com/spf13/cobra"
 
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
 	"github.com/mailslurper/mailslurper/v2/internal/io"
 	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
 	"github.com/mailslurper/mailslurper/v2/internal/model"
@@ -26,7 +27,11 @@ var _ service.Runnable = (*HTTPService)(nil)
 type HTTPServiceConfig struct {
 	Version  string
 	Data     Persistance
+	Faults   handlers.FaultRuleStore
+	Mail     handlers.MailSubscriber
+	Events   handlers.MailEvents
 	Config   *io.Config
+	XSS      sanitizer.IXSSServiceProvider
 	Renderer *ui.TemplateRenderer
 	Logger   *slog.Logger
 }
@@ -44,7 +49,11 @@ func NewHTTPService(config *HTTPServiceConfig) *HTTPService {
 	apiHandler := &APIRouter{
 		Version: config.Version,
 		Data:    config.Data,
+		Faults:  config.Faults,
+		Mail:    config.Mail,
+		Events:  config.Events,
 		Config:  config.Config,
+		XSS:     config.XSS,
 		JWTService: &jwt.JWTService{
 			Config: config.Config,
 		},
@@ -104,13 +113,17 @@ var _ service.Runnable = (*SMTPService)(nil)
 type SMTPService struct {
 	config *io.Config
 	orm    MailWriter
+	xss    sanitizer.IXSSServiceProvider
 	logger *slog.Logger
 
 	// internal state
-	chMail   chan *model.MailItem
-	pool     *smtp.ServerPool
-	listener *smtp.Listener
-	chClose  chan struct{}
+	faults    *smtp.FaultInjector
+	broadcast *BroadcastReceiver
+	events    *EventReceiver
+	chMail    chan *model.MailItem
+	pool      *smtp.ServerPool
+	listener  *smtp.Listener
+	chClose   chan struct{}
 }
 
 func NewSMTPService(
@@ -120,19 +133,36 @@ func NewSMTPService(
 	logger *slog.Logger,
 ) *SMTPService {
 	return &SMTPService{
-		config:  config,
-		orm:     db,
-		logger:  logger,
-		chMail:  make(chan *model.MailItem, 1_000),
-		pool:    smtp.NewServerPool(config.MaxWorkers, xss, logger.With("who", "SMTP Server Pool")),
-		chClose: make(chan struct{}),
+		config:    config,
+		orm:       db,
+		xss:       xss,
+		logger:    logger,
+		faults:    smtp.NewFaultInjector(config.SMTP.Faults),
+		broadcast: NewBroadcastReceiver(),
+		events:    NewEventReceiver(),
+		chMail:    make(chan *model.MailItem, 1_000),
+		chClose:   make(chan struct{}),
 	}
 }
 
 func (s *SMTPService) Start() error {
-	// setup receivers (subscribers) to handle new mail items.
+	pool, err := smtp.NewServerPool(s.config, s.xss, s.faults, s.logger.With("who", "SMTP Server Pool"))
+	if err != nil {
+		s.logger.Error("There was a problem setting up the SMTP worker pool. Exiting...")
+		cobra.CheckErr(err)
+	}
+
+	s.pool = pool
+
+	// setup receivers (subscribers) to handle new mail items. They are only handed mail once the database receiver has
+	// stored it.
 	receivers := []mailslurper.IMailItemReceiver{
-		NewDatabaseReceiver(s.orm, s.logger.With("who", "Database Receiver")),
+		s.broadcast,
+		s.events,
+	}
+
+	if len(s.config.Webhooks) > 0 {
+		receivers = append(receivers, NewWebhookReceiver(s.config.Webhooks, s.logger.With("who", "Webhook Receiver")))
 	}
 
 	// setup the SMTP listener
@@ -141,6 +171,7 @@ func (s *SMTPService) Start() error {
 		s.config.SMTP,
 		s.chMail,
 		s.pool,
+		NewDatabaseReceiver(s.orm, s.logger.With("who", "Database Receiver")),
 		receivers,
 		smtp.NewConnectionManager(s.logger.With("who", "Connection Manager"), s.config, s.chClose, s.chMail, s.pool),
 	)
@@ -166,6 +197,21 @@ func (s *SMTPService) Close() error {
 	return s.listener.Close()
 }
 
+// Faults returns the fault injector shared by all SMTP workers, so its rules can be changed through the admin API.
+func (s *SMTPService) Faults() *smtp.FaultInjector {
+	return s.faults
+}
+
+// Mail returns the receiver passing on stored mail, so the API can wait for mail to arrive.
+func (s *SMTPService) Mail() *BroadcastReceiver {
+	return s.broadcast
+}
+
 func (s *SMTPService) Addr() net.Addr {
 	return s.listener.Addr()
 }
+
+// Events returns the receiver pushing mail events, so the API can stream them and publish the mail it deletes.
+func (s *SMTPService) Events() *EventReceiver {
+	return s.events
+}
@@ -1,9 +1,20 @@
 package app_test
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,9 +34,11 @@ func TestSMTPService_Lifecycle(t *testing.T) {
 	t.Parallel()
 
 	config := &io.Config{
-		SMTP: io.ListenConfig{
-			Address: "127.0.0.1",
-			Port:    0,
+		SMTP: io.SMTPConfig{
+			ListenConfig: io.ListenConfig{
+				Address: "127.0.0.1",
+				Port:    0,
+			},
 		},
 	}
 
@@ -53,34 +66,17 @@ func TestSMTPService_Lifecycle(t *testing.T) {
 func TestSMTPService_SendMail(t *testing.T) {
 	t.Parallel()
 
-	config := &io.Config{
-		MaxWorkers: 5,
-		SMTP: io.ListenConfig{
-			Address: "127.0.0.1",
-			Port:    0, // randomly selects port
-		},
-	}
-
-	xss := sanitizer.NewXSSService()
 	db := new(mocks.MockMailWriter)
-	logger := slog.New(slog.NewTextHandler(tWriter{t: t}, &slog.HandlerOptions{Level: slog.LevelError}))
-
-	svc := app.NewSMTPService(config, xss, db, logger)
-
-	t.Cleanup(func() {
-		assert.NoError(t, svc.Close())
-	})
-
-	go func() {
-		assert.ErrorIs(t, svc.Start(), appsmtp.ErrServerClosed)
-	}()
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
 
 	chSave := make(chan struct{}, 1)
 
 	from := "one@example.com"
 	to1 := "recipient@example.net"
 	to2 := "three@example.com"
-	msg := []byte("To: recipient@example.net\r\n" +
+	msg := []byte("From: Gopher <one@example.com>\r\n" +
+		"To: recipient@example.net\r\n" +
+		"Reply-To: replies@example.com, =?utf-8?q?G=C3=BCnter?= <guenter@example.com>\r\n" +
 		"Subject: discount Gophers!\r\n" +
 		"Date: 02 Jan 2006 15:04:05 -0700\r\n" +
 		"\r\n" +
@@ -93,14 +89,19 @@ func TestSMTPService_SendMail(t *testing.T) {
 		assert.Contains(t, item.ToAddresses, to1)
 		assert.Contains(t, item.ToAddresses, to2)
 
+		// the header addresses are kept apart from the envelope, and a recipient missing from the headers was BCC'd
+		assert.Equal(t, []string{from}, []string(item.HeaderFrom))
+		assert.Equal(t, []string{"replies@example.com", "guenter@example.com"}, []string(item.HeaderReplyTo))
+		assert.Equal(t, []string{to1}, []string(item.HeaderTo))
+		assert.Empty(t, item.HeaderCc)
+		assert.Equal(t, []string{to2}, []string(item.BccAddresses))
+
 		chSave <- struct{}{}
 
 		return true
 	})).Return(nil)
 
-	time.Sleep(time.Second)
 	require.NoError(t, smtp.SendMail(svc.Addr().String(), nil, from, []string{to1, to2}, msg))
-	time.Sleep(time.Second)
 
 	select {
 	case <-chSave:
@@ -111,6 +112,984 @@ func TestSMTPService_SendMail(t *testing.T) {
 	db.AssertExpectations(t)
 }
 
+func TestSMTPService_StartTLSRequired(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		ListenConfig: io.ListenConfig{
+			CertFile: "../../assets/server.crt",
+			KeyFile:  "../../assets/server.key",
+		},
+		StartTLS: io.StartTLSRequired,
+	}, db)
+
+	chSave := make(chan struct{}, 1)
+
+	db.EXPECT().StoreMail(mock.MatchedBy(func(item *model.MailItem) bool {
+		require.NotNil(t, item)
+
+		assert.Equal(t, "one@example.com", item.FromAddress)
+		assert.Contains(t, item.ToAddresses, "recipient@example.net")
+
+		chSave <- struct{}{}
+
+		return true
+	})).Return(nil)
+
+	client := newSMTPClient(t, svc)
+
+	require.NoError(t, client.Hello("localhost"))
+
+	ok, _ := client.Extension("STARTTLS")
+	require.True(t, ok, "STARTTLS should be advertised on a plain connection")
+
+	// mail transactions are rejected until the connection is upgraded
+	assert.ErrorContains(t, client.Mail("one@example.com"), "530")
+
+	require.NoError(t, client.StartTLS(&tls.Config{InsecureSkipVerify: true}))
+
+	ok, _ = client.Extension("STARTTLS")
+	assert.False(t, ok, "STARTTLS should not be advertised after the upgrade")
+
+	require.NoError(t, client.Mail("one@example.com"))
+	require.NoError(t, client.Rcpt("recipient@example.net"))
+
+	writer, err := client.Data()
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Subject: secure Gophers!\r\n\r\nThis is the email body.\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, client.Quit())
+
+	select {
+	case <-chSave:
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestSMTPService_Auth(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		Auth: io.SMTPAuthConfig{
+			Mode: io.SMTPAuthCredentials,
+			Credentials: map[string]string{
+				"service-account": "secret",
+			},
+		},
+	}, db)
+
+	chSave := make(chan string, 2)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item.AuthUser
+	}).Return(nil)
+
+	msg := []byte("Subject: authenticated Gophers!\r\n\r\nThis is the email body.\r\n")
+	tests := []struct {
+		name  string
+		auth  smtp.Auth
+		valid bool
+	}{
+		{name: "PLAIN", auth: smtp.PlainAuth("", "service-account", "secret", "127.0.0.1"), valid: true},
+		{name: "CRAM-MD5", auth: smtp.CRAMMD5Auth("service-account", "secret"), valid: true},
+		{name: "Invalid Password", auth: smtp.PlainAuth("", "service-account", "wrong", "127.0.0.1"), valid: false},
+		{name: "Unknown User", auth: smtp.CRAMMD5Auth("someone-else", "secret"), valid: false},
+	}
+
+	for _, test := range tests {
+		err := smtp.SendMail(svc.Addr().String(), test.auth, "one@example.com", []string{"recipient@example.net"}, msg)
+
+		if !test.valid {
+			assert.ErrorContains(t, err, "535", test.name)
+
+			continue
+		}
+
+		require.NoError(t, err, test.name)
+
+		select {
+		case user := <-chSave:
+			assert.Equal(t, "service-account", user, test.name)
+		case <-t.Context().Done():
+			t.Fail()
+		}
+	}
+}
+
+func TestSMTPService_AuthMechanisms(t *testing.T) {
+	t.Parallel()
+
+	svc := startSMTPService(t, io.SMTPConfig{
+		Auth: io.SMTPAuthConfig{
+			Mode: io.SMTPAuthCredentials,
+			Credentials: map[string]string{
+				"service-account": "secret",
+			},
+		},
+	}, new(mocks.MockMailWriter))
+
+	encode := func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	// answer returns a response that ignores the challenge
+	answer := func(response string) func(string) string {
+		return func(string) string {
+			return response
+		}
+	}
+
+	cramMD5 := func(user, password string) func(string) string {
+		return func(challenge string) string {
+			mac := hmac.New(md5.New, []byte(password))
+			mac.Write([]byte(challenge))
+
+			return encode(user + " " + hex.EncodeToString(mac.Sum(nil)))
+		}
+	}
+
+	tests := []struct {
+		name       string
+		command    string
+		challenges []string
+		responses  []func(string) string
+		code       int
+	}{
+		{
+			name:    "PLAIN initial response",
+			command: "AUTH PLAIN " + encode("\x00service-account\x00secret"),
+			code:    235,
+		},
+		{
+			name:       "PLAIN after challenge",
+			command:    "AUTH PLAIN",
+			challenges: []string{""},
+			responses:  []func(string) string{answer(encode("\x00service-account\x00secret"))},
+			code:       235,
+		},
+		{
+			name:    "PLAIN wrong password",
+			command: "AUTH PLAIN " + encode("\x00service-account\x00wrong"),
+			code:    535,
+		},
+		{
+			name:    "PLAIN bad base64",
+			command: "AUTH PLAIN not-base64!",
+			code:    501,
+		},
+		{
+			name:       "LOGIN",
+			command:    "AUTH LOGIN",
+			challenges: []string{"Username:", "Password:"},
+			responses:  []func(string) string{answer(encode("service-account")), answer(encode("secret"))},
+			code:       235,
+		},
+		{
+			name:       "LOGIN initial response",
+			command:    "AUTH LOGIN " + encode("service-account"),
+			challenges: []string{"Password:"},
+			responses:  []func(string) string{answer(encode("secret"))},
+			code:       235,
+		},
+		{
+			name:       "LOGIN wrong password",
+			command:    "AUTH LOGIN",
+			challenges: []string{"Username:", "Password:"},
+			responses:  []func(string) string{answer(encode("service-account")), answer(encode("wrong"))},
+			code:       535,
+		},
+		{
+			name:       "LOGIN bad base64",
+			command:    "AUTH LOGIN",
+			challenges: []string{"Username:"},
+			responses:  []func(string) string{answer("not-base64!")},
+			code:       501,
+		},
+		{
+			name:       "LOGIN cancelled",
+			command:    "AUTH LOGIN",
+			challenges: []string{"Username:"},
+			responses:  []func(string) string{answer("*")},
+			code:       501,
+		},
+		{
+			name:      "CRAM-MD5",
+			command:   "AUTH CRAM-MD5",
+			responses: []func(string) string{cramMD5("service-account", "secret")},
+			code:      235,
+		},
+		{
+			name:      "CRAM-MD5 wrong password",
+			command:   "AUTH CRAM-MD5",
+			responses: []func(string) string{cramMD5("service-account", "wrong")},
+			code:      535,
+		},
+		{
+			name:      "CRAM-MD5 bad base64",
+			command:   "AUTH CRAM-MD5",
+			responses: []func(string) string{answer("not-base64!")},
+			code:      501,
+		},
+		{
+			name:      "CRAM-MD5 missing digest",
+			command:   "AUTH CRAM-MD5",
+			responses: []func(string) string{answer(encode("service-account"))},
+			code:      501,
+		},
+		{
+			name:    "unknown mechanism",
+			command: "AUTH XOAUTH2",
+			code:    504,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn := dialSMTPService(t, svc)
+			runSMTPSteps(t, conn, smtpStep{command: "EHLO localhost", code: 250})
+
+			_, err := conn.Cmd("%s", test.command)
+			require.NoError(t, err)
+
+			for idx, respond := range test.responses {
+				_, message, err := conn.ReadResponse(334)
+				require.NoError(t, err)
+
+				challenge, err := base64.StdEncoding.DecodeString(message)
+				require.NoError(t, err)
+
+				// the CRAM-MD5 challenge is unique to each exchange and is not checked
+				if idx < len(test.challenges) {
+					assert.Equal(t, test.challenges[idx], string(challenge))
+				}
+
+				_, err = conn.Cmd("%s", respond(string(challenge)))
+				require.NoError(t, err)
+			}
+
+			_, _, err = conn.ReadResponse(test.code)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSMTPService_EHLO(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		Auth: io.SMTPAuthConfig{
+			Mode: io.SMTPAuthAny,
+		},
+	}, db)
+
+	chSave := make(chan string, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item.HeloDomain
+	}).Return(nil)
+
+	client := newSMTPClient(t, svc)
+
+	require.NoError(t, client.Hello("client.example.org"))
+
+	for _, extension := range []string{"SIZE", "8BITMIME", "ENHANCEDSTATUSCODES"} {
+		ok, _ := client.Extension(extension)
+		assert.True(t, ok, extension)
+	}
+
+	ok, mechanisms := client.Extension("AUTH")
+	assert.True(t, ok)
+	assert.Equal(t, "PLAIN LOGIN CRAM-MD5", mechanisms)
+
+	ok, _ = client.Extension("STARTTLS")
+	assert.False(t, ok, "STARTTLS should not be advertised without a certificate")
+
+	// the client adds BODY=8BITMIME to MAIL FROM because 8BITMIME is advertised
+	require.NoError(t, client.Mail("one@example.com"))
+	require.NoError(t, client.Rcpt("recipient@example.net"))
+
+	writer, err := client.Data()
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Subject: extended Gophers!\r\n\r\nThis is the email body.\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, client.Quit())
+
+	select {
+	case domain := <-chSave:
+		assert.Equal(t, "client.example.org", domain)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+}
+
+func TestSMTPService_ErrorReplies(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	conn := dialSMTPService(t, svc)
+
+	// every command is answered and the session stays open after each rejection
+	tests := []struct {
+		command string
+		code    int
+		message string
+	}{
+		{command: "MAIL FROM:<one@example.com>", code: 503, message: "5.5.1"},
+		{command: "EHLO localhost", code: 250},
+		{command: "STARTTLS", code: 502, message: "5.5.1"},
+		{command: "VRFY someone", code: 502, message: "5.5.1"},
+		{command: "HELP", code: 502, message: "5.5.1"},
+		{command: "BOGUS", code: 500, message: "5.5.2"},
+		{command: "NOOP " + strings.Repeat("x", 13000), code: 500, message: "5.5.6 Line too long"},
+		{command: "RCPT TO:<recipient@example.net>", code: 503, message: "5.5.1"},
+		{command: "DATA", code: 503, message: "5.5.1"},
+		{command: "MAIL FROM:<not an address>", code: 501, message: "5.1.7"},
+		{command: "MAIL FROM:<one@example.com> FOO=BAR", code: 555, message: "5.5.4"},
+		{command: "MAIL FROM:<one@example.com>", code: 250},
+		{command: "MAIL FROM:<one@example.com>", code: 503, message: "5.5.1"},
+		{command: "RCPT TO:<@@>", code: 501, message: "5.1.3"},
+		{command: "DATA", code: 503, message: "5.5.1"},
+		{command: "RSET extra", code: 501, message: "5.5.4"},
+		{command: "RSET", code: 250},
+		{command: "RCPT TO:<recipient@example.net>", code: 503, message: "5.5.1"},
+		{command: "NOOP", code: 250},
+	}
+
+	for _, test := range tests {
+		_, err := conn.Cmd("%s", test.command)
+		require.NoError(t, err, test.command)
+
+		_, message, err := conn.ReadResponse(test.code)
+		assert.NoError(t, err, test.command)
+		assert.Contains(t, message, test.message, test.command)
+	}
+
+	_, err := conn.Cmd("QUIT")
+	require.NoError(t, err)
+
+	_, _, err = conn.ReadResponse(221)
+	assert.NoError(t, err)
+}
+
+func TestSMTPService_MaxMessageSize(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		MaxMessageSize: 1024,
+	}, db)
+
+	chSave := make(chan string, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item.Subject
+	}).Return(nil).Once()
+
+	client := newSMTPClient(t, svc)
+
+	require.NoError(t, client.Hello("localhost"))
+
+	ok, size := client.Extension("SIZE")
+	assert.True(t, ok)
+	assert.Equal(t, "1024", size)
+
+	// a declared size over the limit is refused up front
+	id, err := client.Text.Cmd("MAIL FROM:<one@example.com> SIZE=4096")
+	require.NoError(t, err)
+
+	client.Text.StartResponse(id)
+	_, _, err = client.Text.ReadResponse(250)
+	client.Text.EndResponse(id)
+	assert.ErrorContains(t, err, "552")
+
+	// an undeclared message over the limit is refused at the end of DATA
+	require.NoError(t, client.Mail("one@example.com"))
+	require.NoError(t, client.Rcpt("recipient@example.net"))
+
+	writer, err := client.Data()
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Subject: big Gophers!\r\n\r\n" + strings.Repeat("This is the email body.\r\n", 100)))
+	require.NoError(t, err)
+	assert.ErrorContains(t, writer.Close(), "552")
+
+	// the session carries on
+	require.NoError(t, client.Mail("one@example.com"))
+	require.NoError(t, client.Rcpt("recipient@example.net"))
+
+	writer, err = client.Data()
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Subject: small Gophers!\r\n\r\nThis is the email body.\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, client.Quit())
+
+	select {
+	case subject := <-chSave:
+		assert.Equal(t, "small Gophers!", subject)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestSMTPService_Pipelining(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	chSave := make(chan *model.MailItem, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil)
+
+	conn := dialSMTPService(t, svc)
+
+	_, err := conn.Cmd("EHLO localhost")
+	require.NoError(t, err)
+
+	_, message, err := conn.ReadResponse(250)
+	require.NoError(t, err)
+	assert.Contains(t, message, "PIPELINING")
+
+	// the whole envelope goes out in a single write
+	_, err = conn.W.WriteString("MAIL FROM:<one@example.com>\r\n" +
+		"RCPT TO:<two@example.com>\r\n" +
+		"RCPT TO:<@@>\r\n" +
+		"RCPT TO:<three@example.com>\r\n" +
+		"DATA\r\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 250, 501, 250, 354} {
+		_, _, err = conn.ReadResponse(code)
+		assert.NoError(t, err)
+	}
+
+	_, err = conn.W.WriteString("Subject: pipelined Gophers!\r\n\r\nThis is the email body.\r\n.\r\nQUIT\r\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 221} {
+		_, _, err = conn.ReadResponse(code)
+		assert.NoError(t, err)
+	}
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "one@example.com", item.FromAddress)
+		assert.ElementsMatch(t, []string{"two@example.com", "three@example.com"}, item.ToAddresses)
+		assert.Equal(t, "pipelined Gophers!", item.Subject)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+}
+
+func TestSMTPService_Chunking(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		MaxMessageSize: 1024,
+	}, db)
+
+	chSave := make(chan *model.MailItem, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil).Once()
+
+	conn := dialSMTPService(t, svc)
+
+	_, err := conn.Cmd("EHLO localhost")
+	require.NoError(t, err)
+
+	_, message, err := conn.ReadResponse(250)
+	require.NoError(t, err)
+	assert.Contains(t, message, "CHUNKING")
+
+	// chunks are binary: no dot stuffing and no terminator
+	headers := "Subject: chunked Gophers!\r\n\r\n"
+	body := ".This is the email body.\r\n"
+
+	_, err = conn.W.WriteString("MAIL FROM:<one@example.com>\r\nRCPT TO:<two@example.com>\r\n" +
+		fmt.Sprintf("BDAT %d\r\n%s", len(headers), headers) +
+		fmt.Sprintf("BDAT %d LAST\r\n%s", len(body), body))
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 250, 250, 250} {
+		_, _, err = conn.ReadResponse(code)
+		assert.NoError(t, err)
+	}
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "chunked Gophers!", item.Subject)
+		assert.Contains(t, item.Body, ".This is the email body.")
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	// an oversized chunk is read and rejected, and any chunks pipelined after it are thrown away
+	oversized := strings.Repeat("x", 2048)
+
+	_, err = conn.W.WriteString("MAIL FROM:<one@example.com>\r\nRCPT TO:<two@example.com>\r\n" +
+		fmt.Sprintf("BDAT %d\r\n%s", len(oversized), oversized) +
+		fmt.Sprintf("BDAT %d LAST\r\n%s", len(body), body) +
+		"DATA\r\nQUIT\r\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 250, 552, 503, 503, 221} {
+		_, _, err = conn.ReadResponse(code)
+		assert.NoError(t, err)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestSMTPService_LooseMessages(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	chSave := make(chan *model.MailItem, 2)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil).Twice()
+
+	conn := dialSMTPService(t, svc)
+
+	_, err := conn.Cmd("EHLO localhost")
+	require.NoError(t, err)
+
+	_, _, err = conn.ReadResponse(250)
+	require.NoError(t, err)
+
+	// a message of nothing but headers has an empty body
+	_, err = conn.W.WriteString("MAIL FROM:<one@example.com>\r\nRCPT TO:<two@example.com>\r\nDATA\r\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 250, 354} {
+		_, _, err = conn.ReadResponse(code)
+		require.NoError(t, err)
+	}
+
+	_, err = conn.W.WriteString("Subject: Headers only\r\n.\r\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	_, _, err = conn.ReadResponse(250)
+	require.NoError(t, err)
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "Headers only", item.Subject)
+		assert.Empty(t, item.Body)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	// chunks are sent as is, so a message with bare line feeds arrives with them in place
+	message := "Subject: Bare line feeds\nContent-Type: text/plain\n\nFirst line\nSecond line\n"
+
+	_, err = conn.W.WriteString("MAIL FROM:<one@example.com>\r\nRCPT TO:<two@example.com>\r\n" +
+		fmt.Sprintf("BDAT %d LAST\r\n%s", len(message), message))
+	require.NoError(t, err)
+	require.NoError(t, conn.W.Flush())
+
+	for _, code := range []int{250, 250, 250} {
+		_, _, err = conn.ReadResponse(code)
+		assert.NoError(t, err)
+	}
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "Bare line feeds", item.Subject)
+		assert.Equal(t, "First line\nSecond line\n", item.Body)
+		assert.Equal(t, item.Body, item.TextBody)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestSMTPService_SMTPUTF8(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	chSave := make(chan *model.MailItem, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil)
+
+	conn := dialSMTPService(t, svc)
+
+	_, err := conn.Cmd("EHLO localhost")
+	require.NoError(t, err)
+
+	_, message, err := conn.ReadResponse(250)
+	require.NoError(t, err)
+	assert.Contains(t, message, "SMTPUTF8")
+
+	// UTF-8 addresses are refused unless the transaction asked for SMTPUTF8
+	for _, step := range []struct {
+		command string
+		code    int
+	}{
+		{command: "MAIL FROM:<josé@bücher.de>", code: 553},
+		{command: "MAIL FROM:<sender@example.com>", code: 250},
+		{command: "RCPT TO:<josé@bücher.de>", code: 553},
+		{command: "RSET", code: 250},
+		{command: "MAIL FROM:<josé@bücher.de> SMTPUTF8", code: 250},
+		{command: "RCPT TO:<\"quoted local\"@example.com>", code: 250},
+		{command: "RCPT TO:<用户@例子.广告>", code: 250},
+		{command: "DATA", code: 354},
+	} {
+		_, err = conn.Cmd("%s", step.command)
+		require.NoError(t, err)
+
+		_, message, err = conn.ReadResponse(step.code)
+		assert.NoError(t, err, step.command)
+
+		if step.code == 553 {
+			assert.True(t, strings.HasPrefix(message, "5.6.7 "), message)
+		}
+	}
+
+	_, err = conn.Cmd("Subject: Grüße\r\n\r\nThis is the email body.\r\n.")
+	require.NoError(t, err)
+
+	_, _, err = conn.ReadResponse(250)
+	require.NoError(t, err)
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "josé@bücher.de", item.FromAddress)
+		assert.Equal(t, []string{"\"quoted local\"@example.com", "用户@例子.广告"}, []string(item.ToAddresses))
+	case <-t.Context().Done():
+		t.Fail()
+	}
+}
+
+func TestSMTPService_Charsets(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	chSave := make(chan *model.MailItem, 2)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil)
+
+	conn := dialSMTPService(t, svc)
+
+	_, err := conn.Cmd("EHLO localhost")
+	require.NoError(t, err)
+
+	_, _, err = conn.ReadResponse(250)
+	require.NoError(t, err)
+
+	messages := []string{
+		"Subject: Single\r\n" +
+			"Content-Type: text/plain; charset=windows-1252\r\n" +
+			"Content-Transfer-Encoding: quoted-printable\r\n" +
+			"\r\n" +
+			"Caf=E9 =80 5\r\n" +
+			"second line <b>\r\n.",
+		"Subject: Multipart\r\n" +
+			"Content-Type: multipart/alternative; boundary=b\r\n" +
+			"\r\n" +
+			"--b\r\n" +
+			"Content-Type: text/plain; charset=ISO-8859-1\r\n" +
+			"Content-Transfer-Encoding: base64\r\n" +
+			"\r\n" +
+			"Q2Fm6Q==\r\n" +
+			"--b\r\n" +
+			"Content-Type: text/html; charset=Shift_JIS\r\n" +
+			"\r\n" +
+			"<p>\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd</p>\r\n" +
+			"--b--\r\n.",
+	}
+
+	for _, message := range messages {
+		runSMTPSteps(t, conn,
+			smtpStep{command: "MAIL FROM:<sender@example.com>", code: 250},
+			smtpStep{command: "RCPT TO:<recipient@example.com>", code: 250},
+			smtpStep{command: "DATA", code: 354},
+			smtpStep{command: message, code: 250},
+		)
+	}
+
+	select {
+	case item := <-chSave:
+		// plain text is stored as sent, without being turned into HTML
+		assert.Equal(t, "Café € 5\r\nsecond line <b>\r\n", item.Body)
+		assert.Equal(t, item.Body, item.TextBody)
+		assert.Empty(t, item.HTMLBody)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, "Café", item.TextBody)
+		assert.Equal(t, "<p>こんにちは</p>", item.HTMLBody)
+		assert.Equal(t, item.HTMLBody, item.Body)
+	case <-t.Context().Done():
+		t.Fail()
+	}
+}
+
+func TestSMTPService_Faults(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		Faults: io.FaultConfig{
+			Recipients: []io.RecipientFault{{Pattern: "*@bounce.test"}},
+		},
+	}, db)
+
+	// recipient patterns from the config are refused with 550
+	conn := dialSMTPService(t, svc)
+	runSMTPSteps(t, conn,
+		smtpStep{command: "EHLO localhost", code: 250},
+		smtpStep{command: "MAIL FROM:<sender@example.com>", code: 250},
+		smtpStep{command: "RCPT TO:<Someone@Bounce.test>", code: 550},
+		smtpStep{command: "RCPT TO:<someone@example.com>", code: 250},
+	)
+
+	// rules changed at runtime apply to the next command
+	require.NoError(t, svc.Faults().SetRules(io.FaultConfig{
+		Latency: "10ms",
+		Rcpt:    io.FaultRate{Percent: 100, Code: 452, Message: "Too many recipients"},
+		Data:    io.FaultRate{Percent: 100},
+	}))
+
+	_, err := conn.Cmd("RCPT TO:<another@example.com>")
+	require.NoError(t, err)
+
+	_, message, err := conn.ReadResponse(452)
+	assert.NoError(t, err)
+	assert.Equal(t, "4.0.0 Too many recipients", message)
+
+	// a message refused after DATA ends the transaction
+	runSMTPSteps(t, conn,
+		smtpStep{command: "DATA", code: 354},
+		smtpStep{command: "Subject: faulty\r\n\r\nbody\r\n.", code: 451},
+		smtpStep{command: "RCPT TO:<someone@example.com>", code: 503},
+	)
+
+	require.Error(t, svc.Faults().SetRules(io.FaultConfig{DropPercent: 101}))
+	require.NoError(t, svc.Faults().SetRules(io.FaultConfig{DropPercent: 100}))
+
+	// the connection is closed once the client starts sending the message
+	raw, err := net.Dial("tcp", svc.Addr().String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = raw.Close()
+	})
+
+	conn = textproto.NewConn(raw)
+
+	_, _, err = conn.ReadResponse(220)
+	require.NoError(t, err)
+
+	runSMTPSteps(t, conn,
+		smtpStep{command: "EHLO localhost", code: 250},
+		smtpStep{command: "MAIL FROM:<sender@example.com>", code: 250},
+		smtpStep{command: "RCPT TO:<someone@example.com>", code: 250},
+		smtpStep{command: "DATA", code: 354},
+	)
+
+	// nothing happens until message data arrives
+	require.NoError(t, raw.SetReadDeadline(time.Now().Add(250*time.Millisecond)))
+
+	var netErr net.Error
+
+	_, err = conn.ReadLine()
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout(), "connection should still be open before message data is sent")
+
+	require.NoError(t, raw.SetReadDeadline(time.Time{}))
+
+	_, err = conn.Cmd("Subject: dropped\r\n\r\nbody\r\n.")
+	if err == nil {
+		_, _, err = conn.ReadResponse(250)
+	}
+
+	assert.Error(t, err)
+
+	db.AssertNotCalled(t, "StoreMail", mock.Anything)
+}
+
+func TestSMTPService_Greylist(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{
+		Greylist: io.GreylistConfig{
+			Enabled: true,
+			Delay:   "1s",
+		},
+	}, db)
+
+	chSave := make(chan *model.MailItem, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(item *model.MailItem) {
+		chSave <- item
+	}).Return(nil)
+
+	conn := dialSMTPService(t, svc)
+
+	rcpt := func(address string, code int) {
+		_, err := conn.Cmd("RCPT TO:<%s>", address)
+		require.NoError(t, err)
+
+		_, message, err := conn.ReadResponse(code)
+		assert.NoError(t, err, address)
+
+		if code == 451 {
+			assert.True(t, strings.HasPrefix(message, "4.7.1 "), message)
+		}
+	}
+
+	runSMTPSteps(t, conn,
+		smtpStep{command: "EHLO localhost", code: 250},
+		smtpStep{command: "MAIL FROM:<sender@example.com>", code: 250},
+	)
+
+	rcpt("one@example.com", 451)
+	rcpt("One@Example.com", 451)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// the retry is accepted once the delay has passed, while a new triplet is greylisted
+	rcpt("one@example.com", 250)
+	rcpt("two@example.com", 451)
+
+	runSMTPSteps(t, conn,
+		smtpStep{command: "DATA", code: 354},
+		smtpStep{command: "Subject: greylisted\r\n\r\nbody\r\n.", code: 250},
+	)
+
+	select {
+	case item := <-chSave:
+		assert.Equal(t, []string{"one@example.com"}, []string(item.ToAddresses))
+	case <-t.Context().Done():
+		t.Fail()
+	}
+}
+
+func TestSMTPService_Mail(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	var stored atomic.Bool
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(_ *model.MailItem) {
+		// give a subscriber the chance to hear of the mail early, if it were to
+		time.Sleep(100 * time.Millisecond)
+		stored.Store(true)
+	}).Return(nil)
+
+	chMail, unsubscribe := svc.Mail().Subscribe()
+	defer unsubscribe()
+
+	chEvents, unsubscribeEvents := svc.Events().Subscribe()
+	defer unsubscribeEvents()
+
+	require.NoError(t, smtp.SendMail(svc.Addr().String(), nil, "one@example.com", []string{"two@example.com"},
+		[]byte("Subject: waited for\r\n\r\nbody\r\n")))
+
+	select {
+	case item := <-chMail:
+		assert.Equal(t, "waited for", item.Subject)
+		assert.True(t, stored.Load(), "subscribers should only hear of stored mail")
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail was not passed on to subscribers")
+	}
+
+	select {
+	case event := <-chEvents:
+		assert.Equal(t, model.MailReceivedEvent, event.Type)
+		assert.Equal(t, "waited for", event.Mail.Subject)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail.received event was not published")
+	}
+}
+
+func TestSMTPService_Mail_NotStored(t *testing.T) {
+	t.Parallel()
+
+	db := new(mocks.MockMailWriter)
+	svc := startSMTPService(t, io.SMTPConfig{}, db)
+
+	chStore := make(chan struct{}, 1)
+
+	db.EXPECT().StoreMail(mock.AnythingOfType("*model.MailItem")).Run(func(_ *model.MailItem) {
+		chStore <- struct{}{}
+	}).Return(errors.New("database is locked")).Once()
+
+	chMail, unsubscribe := svc.Mail().Subscribe()
+	defer unsubscribe()
+
+	chEvents, unsubscribeEvents := svc.Events().Subscribe()
+	defer unsubscribeEvents()
+
+	require.NoError(t, smtp.SendMail(svc.Addr().String(), nil, "one@example.com", []string{"two@example.com"},
+		[]byte("Subject: never stored\r\n\r\nbody\r\n")))
+
+	select {
+	case <-chStore:
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail was not handed to the database")
+	}
+
+	// mail that failed to store goes no further than the database receiver
+	select {
+	case item := <-chMail:
+		t.Fatalf("mail %s was passed on to subscribers", item.ID)
+	case event := <-chEvents:
+		t.Fatalf("%s event was published", event.Type)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	db.AssertExpectations(t)
+}
+
 func TestHTTPService_Lifecycle(t *testing.T) {
 	t.Parallel()
 
@@ -128,6 +1107,7 @@ func TestHTTPService_Lifecycle(t *testing.T) {
 		Version: "test",
 		Data:    db,
 		Config:  config,
+		XSS:     sanitizer.NewXSSService(),
 		Logger:  logger,
 	})
 
@@ -146,6 +1126,89 @@ func TestHTTPService_Lifecycle(t *testing.T) {
 	assert.ErrorIs(t, svc.Start(), http.ErrServerClosed)
 }
 
+// startSMTPService starts an SMTP service on a random local port, passing received mail to db. The listen address
+// and port of the SMTP settings are filled in. The service is closed when the test ends.
+func startSMTPService(t *testing.T, smtpConfig io.SMTPConfig, db *mocks.MockMailWriter) *app.SMTPService {
+	t.Helper()
+
+	smtpConfig.ListenConfig.Address = "127.0.0.1"
+	smtpConfig.ListenConfig.Port = 0 // randomly selects port
+
+	require.NoError(t, smtpConfig.Validate())
+
+	config := &io.Config{
+		MaxWorkers: 5,
+		SMTP:       smtpConfig,
+	}
+
+	logger := slog.New(slog.NewTextHandler(tWriter{t: t}, &slog.HandlerOptions{Level: slog.LevelError}))
+	svc := app.NewSMTPService(config, sanitizer.NewXSSService(), db, logger)
+
+	t.Cleanup(func() {
+		assert.NoError(t, svc.Close())
+	})
+
+	go func() {
+		assert.ErrorIs(t, svc.Start(), appsmtp.ErrServerClosed)
+	}()
+
+	// give the listener time to start
+	time.Sleep(time.Second)
+
+	return svc
+}
+
+// dialSMTPService opens a connection to the SMTP service and reads its greeting. The connection is closed when the
+// test ends.
+func dialSMTPService(t *testing.T, svc *app.SMTPService) *textproto.Conn {
+	t.Helper()
+
+	conn, err := textproto.Dial("tcp", svc.Addr().String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	_, _, err = conn.ReadResponse(220)
+	require.NoError(t, err)
+
+	return conn
+}
+
+// smtpStep is a command sent to the SMTP service and the reply code it should get.
+type smtpStep struct {
+	command string
+	code    int
+}
+
+// runSMTPSteps sends each command in turn and checks the code of its reply.
+func runSMTPSteps(t *testing.T, conn *textproto.Conn, steps ...smtpStep) {
+	t.Helper()
+
+	for _, step := range steps {
+		_, err := conn.Cmd("%s", step.command)
+		require.NoError(t, err, step.command)
+
+		_, _, err = conn.ReadResponse(step.code)
+		assert.NoError(t, err, step.command)
+	}
+}
+
+// newSMTPClient connects an SMTP client to the service. The client is closed when the test ends.
+func newSMTPClient(t *testing.T, svc *app.SMTPService) *smtp.Client {
+	t.Helper()
+
+	client, err := smtp.Dial(svc.Addr().String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
 type tWriter struct {
 	t *testing.T
 }
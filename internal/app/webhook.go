@@ -0,0 +1,269 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the request body as "sha256=<hex>" when a webhook has a secret.
+	WebhookSignatureHeader = "X-MailSlurper-Signature"
+	// WebhookEventHeader carries the type of event posted to a webhook.
+	WebhookEventHeader = "X-MailSlurper-Event"
+
+	// webhookExcerptLength is the number of characters of the body included in a webhook summary.
+	webhookExcerptLength = 500
+)
+
+var ErrWebhookRejected = errors.New("webhook rejected the request")
+
+// WebhookPayload is the JSON summary of a received mail posted to webhooks.
+type WebhookPayload struct {
+	Event       string              `json:"event"`
+	ID          uuid.UUID           `json:"id"`
+	ReceivedAt  time.Time           `json:"receivedAt"`
+	Envelope    WebhookEnvelope     `json:"envelope"`
+	DateSent    string              `json:"dateSent"`
+	Subject     string              `json:"subject"`
+	Headers     []model.MailHeader  `json:"headers"`
+	BodyExcerpt string              `json:"bodyExcerpt"`
+	Attachments []WebhookAttachment `json:"attachments"`
+}
+
+// WebhookEnvelope holds the SMTP envelope of a received mail.
+type WebhookEnvelope struct {
+	From       string   `json:"from"`
+	To         []string `json:"to"`
+	HeloDomain string   `json:"heloDomain"`
+	AuthUser   string   `json:"authUser,omitempty"`
+}
+
+// WebhookAttachment describes an attachment or inline part of a received mail, without its contents.
+type WebhookAttachment struct {
+	ID          uuid.UUID `json:"id"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	ContentID   string    `json:"contentId,omitempty"`
+	Inline      bool      `json:"inline"`
+}
+
+// NewWebhookPayload creates the summary of a mail item posted to webhooks.
+func NewWebhookPayload(mailItem *model.MailItem) *WebhookPayload {
+	attachments := make([]WebhookAttachment, 0, len(mailItem.Attachments)+len(mailItem.InlineAttachments))
+
+	for _, attachment := range append(append([]model.Attachment{}, mailItem.Attachments...), mailItem.InlineAttachments...) {
+		attachments = append(attachments, WebhookAttachment{
+			ID:          attachment.ID,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			ContentID:   attachment.ContentID,
+			Inline:      attachment.Inline,
+		})
+	}
+
+	// mail without a plain text alternative only has its display body to take an excerpt from
+	body := mailItem.TextBody
+	if body == "" {
+		body = mailItem.Body
+	}
+
+	return &WebhookPayload{
+		Event:      model.MailReceivedEvent,
+		ID:         mailItem.ID,
+		ReceivedAt: mailItem.CreatedAt,
+		Envelope: WebhookEnvelope{
+			From:       mailItem.FromAddress,
+			To:         mailItem.ToAddresses,
+			HeloDomain: mailItem.HeloDomain,
+			AuthUser:   mailItem.AuthUser,
+		},
+		DateSent:    mailItem.DateSent,
+		Subject:     mailItem.Subject,
+		Headers:     mailItem.Headers,
+		BodyExcerpt: excerpt(body, webhookExcerptLength),
+		Attachments: attachments,
+	}
+}
+
+// WebhookReceiver posts a summary of each mail item to the configured webhooks. Deliveries to each webhook are made at
+// the same time, and each is retried with a growing delay until it is accepted or runs out of attempts.
+type WebhookReceiver struct {
+	webhooks []io.WebhookConfig
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+// NewWebhookReceiver creates a new WebhookReceiver object.
+func NewWebhookReceiver(webhooks []io.WebhookConfig, logger *slog.Logger) *WebhookReceiver {
+	return &WebhookReceiver{
+		webhooks: webhooks,
+		client:   &http.Client{},
+		logger:   logger,
+	}
+}
+
+// Receive posts a summary of the mail item to every webhook whose filters it matches. It returns the errors of the
+// deliveries that were given up on.
+func (r *WebhookReceiver) Receive(mailItem *model.MailItem) error {
+	body, err := json.Marshal(NewWebhookPayload(mailItem))
+	if err != nil {
+		return fmt.Errorf("%w: problem encoding webhook summary of mail item %s", err, mailItem.ID)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, webhook := range r.webhooks {
+		if !webhookMatches(webhook, mailItem) {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := r.deliver(webhook, body); err != nil {
+				r.logger.Error(fmt.Sprintf("Giving up on webhook delivery of mail item %s: %s", mailItem.ID, err))
+
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliver posts the body to a webhook, retrying failed attempts with a doubling delay. Requests the webhook rejects
+// with a 4xx status other than 408 or 429 are not retried.
+func (r *WebhookReceiver) deliver(webhook io.WebhookConfig, body []byte) error {
+	delay := webhook.GetRetryDelay()
+
+	var err error
+
+	for attempt := 1; attempt <= webhook.GetMaxAttempts(); attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var retry bool
+
+		if retry, err = r.post(webhook, body); err == nil || !retry {
+			return err
+		}
+
+		r.logger.Debug(fmt.Sprintf("Webhook delivery attempt %d to %s failed: %s", attempt, webhook.URL, err))
+	}
+
+	return err
+}
+
+// post makes a single delivery attempt, returning whether a failed attempt may be retried.
+func (r *WebhookReceiver) post(webhook io.WebhookConfig, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhook.GetTimeout())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("%w: problem creating webhook request to %s", err, webhook.URL)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, model.MailReceivedEvent)
+
+	if webhook.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, SignWebhookBody(webhook.Secret, body))
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return true, fmt.Errorf("%w: problem posting to webhook %s", err, webhook.URL)
+	}
+
+	_ = response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := response.StatusCode >= 500 ||
+		response.StatusCode == http.StatusRequestTimeout ||
+		response.StatusCode == http.StatusTooManyRequests
+
+	return retry, fmt.Errorf("%w: %s answered %s", ErrWebhookRejected, webhook.URL, response.Status)
+}
+
+// SignWebhookBody returns the signature header value for a webhook request body.
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookMatches returns true if the mail item passes the recipient and sender filters of a webhook. A webhook without
+// filters takes every mail item.
+func webhookMatches(webhook io.WebhookConfig, mailItem *model.MailItem) bool {
+	if len(webhook.Senders) > 0 && !matchesAnyPattern(webhook.Senders, mailItem.FromAddress) {
+		return false
+	}
+
+	if len(webhook.Recipients) == 0 {
+		return true
+	}
+
+	for _, recipient := range mailItem.ToAddresses {
+		if matchesAnyPattern(webhook.Recipients, recipient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAnyPattern(patterns []string, address string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(address)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excerpt returns up to length characters from the start of the text, with surrounding whitespace removed.
+func excerpt(text string, length int) string {
+	text = strings.TrimSpace(text)
+
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	return strings.TrimSpace(string([]rune(text)[:length]))
+}
@@ -0,0 +1,155 @@
+package app_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/app"
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestWebhookReceiver_Receive(t *testing.T) {
+	t.Parallel()
+
+	mailItem := &model.MailItem{
+		ID:          uuid.Must(uuid.NewV4()),
+		FromAddress: "app@x.test",
+		ToAddresses: model.MailAddressCollection{"alice@x.test"},
+		Subject:     "Reset your password",
+		TextBody:    "  Follow the link to reset your password. " + strings.Repeat("x", 1000),
+		Headers:     []model.MailHeader{{Name: "Subject", Value: "Reset your password"}},
+		Attachments: []model.Attachment{
+			{ID: uuid.Must(uuid.NewV4()), FileName: "a.pdf", ContentType: "application/pdf", Contents: "%PDF"},
+		},
+	}
+
+	t.Run("signed summary", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			body      []byte
+			signature string
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			body, _ = io.ReadAll(request.Body)
+			signature = request.Header.Get(app.WebhookSignatureHeader)
+
+			assert.Equal(t, model.MailReceivedEvent, request.Header.Get(app.WebhookEventHeader))
+			assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		}))
+		t.Cleanup(server.Close)
+
+		receiver := app.NewWebhookReceiver([]slurperio.WebhookConfig{
+			{URL: server.URL, Secret: "s3cret"},
+		}, slog.New(slog.DiscardHandler))
+
+		require.NoError(t, receiver.Receive(mailItem))
+
+		assert.Equal(t, app.SignWebhookBody("s3cret", body), signature)
+
+		var payload app.WebhookPayload
+
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, mailItem.ID, payload.ID)
+		assert.Equal(t, "app@x.test", payload.Envelope.From)
+		assert.Equal(t, []string{"alice@x.test"}, payload.Envelope.To)
+		assert.Equal(t, "Reset your password", payload.Subject)
+		assert.Equal(t, mailItem.Headers, payload.Headers)
+		assert.True(t, strings.HasPrefix(payload.BodyExcerpt, "Follow the link"))
+		assert.Len(t, []rune(payload.BodyExcerpt), 500)
+		require.Len(t, payload.Attachments, 1)
+		assert.Equal(t, "a.pdf", payload.Attachments[0].FileName)
+		assert.NotContains(t, string(body), "%PDF", "attachment contents should not be sent")
+	})
+
+	t.Run("retries with backoff", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			if attempts.Add(1) < 3 {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		receiver := app.NewWebhookReceiver([]slurperio.WebhookConfig{
+			{URL: server.URL, RetryDelay: "10ms"},
+		}, slog.New(slog.DiscardHandler))
+
+		require.NoError(t, receiver.Receive(mailItem))
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+
+		receiver := app.NewWebhookReceiver([]slurperio.WebhookConfig{
+			{URL: server.URL, MaxAttempts: 2, RetryDelay: "10ms"},
+		}, slog.New(slog.DiscardHandler))
+
+		assert.ErrorIs(t, receiver.Receive(mailItem), app.ErrWebhookRejected)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			writer.WriteHeader(http.StatusBadRequest)
+		}))
+		t.Cleanup(server.Close)
+
+		receiver := app.NewWebhookReceiver([]slurperio.WebhookConfig{
+			{URL: server.URL, RetryDelay: "10ms"},
+		}, slog.New(slog.DiscardHandler))
+
+		assert.ErrorIs(t, receiver.Receive(mailItem), app.ErrWebhookRejected)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+		}))
+		t.Cleanup(server.Close)
+
+		receiver := app.NewWebhookReceiver([]slurperio.WebhookConfig{
+			{URL: server.URL, Recipients: []string{"*@X.TEST"}},
+			{URL: server.URL, Recipients: []string{"bob@x.test"}},
+			{URL: server.URL, Senders: []string{"app@*"}},
+			{URL: server.URL, Senders: []string{"app@*"}, Recipients: []string{"*@y.test"}},
+		}, slog.New(slog.DiscardHandler))
+
+		require.NoError(t, receiver.Receive(mailItem))
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+}
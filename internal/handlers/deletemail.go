@@ -6,12 +6,17 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/gofrs/uuid"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/requests"
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
 
 type MailRemover interface {
 	DeleteMailsAfterDate(string) (int64, error)
+	DeleteMailByID(uuid.UUID) error
 }
 
 type ParamFunc func(*http.Request, string) string
@@ -20,11 +25,13 @@ type DeleteMailParams struct {
 	Prune string `form:"prune,omitempty" json:"prune,omitempty"`
 }
 
-// DeleteMail is a request to delete mail items. This expects a param containing a valid prune code.
+// DeleteMail is a request to delete mail items. This expects a param containing a valid prune code. A mail.pruned event
+// is published when events are available.
 //
 // DELETE: /mail?prune={pruneCode}
 func DeleteMail(
 	data MailRemover,
+	events MailEventPublisher,
 	logger *log.Logger,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -61,6 +68,10 @@ func DeleteMail(
 			return
 		}
 
+		if events != nil {
+			events.Publish(model.NewMailPrunedEvent(pruneCode.String(), rowsDeleted))
+		}
+
 		logger.Printf("Deleting %d mails, code %s before %s", rowsDeleted, pruneCode.String(), startDate)
 		response.RenderOrLog(writer, request, &response.TextResponse{
 			HTTPStatusCode: http.StatusOK,
@@ -69,6 +80,42 @@ func DeleteMail(
 	}
 }
 
+// DeleteMailItem deletes a single mail item. A mail.deleted event is published when events are available.
+//
+// DELETE: /mail/{mailId}
+func DeleteMailItem(
+	data MailRemover,
+	events MailEventPublisher,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		mailItem := middleware.GetMailItem(request.Context())
+		if err := response.ValidContextsAndMethod(request, http.MethodDelete, mailItem); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		if err := data.DeleteMailByID(mailItem.ID); err != nil {
+			err = fmt.Errorf("%w: problem deleting mail item %s", err, mailItem.ID)
+
+			response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+			return
+		}
+
+		if events != nil {
+			events.Publish(model.NewMailDeletedEvent(mailItem))
+		}
+
+		logger.Printf("Mail item %s deleted", mailItem.ID)
+		response.RenderOrLog(writer, request, &response.TextResponse{
+			HTTPStatusCode: http.StatusOK,
+			Data:           []byte("1"),
+		}, logger)
+	}
+}
+
 // GetPruneOptions retrieves the set of options available to users for pruning.
 func GetPruneOptions(logger *log.Logger) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
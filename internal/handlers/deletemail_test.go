@@ -8,18 +8,22 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/requests"
 	"github.com/mailslurper/mailslurper/v2/internal/mocks"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
 
 func TestDeleteMail_InvalidMethod(t *testing.T) {
 	t.Parallel()
 
 	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
-	handler := handlers.DeleteMail(nil, logger)
+	handler := handlers.DeleteMail(nil, nil, logger)
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -36,7 +40,7 @@ func TestDeleteMail_InvalidParam(t *testing.T) {
 
 	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
 	router := chi.NewRouter()
-	handler := handlers.DeleteMail(nil, logger)
+	handler := handlers.DeleteMail(nil, nil, logger)
 
 	router.Delete("/mail", handler)
 
@@ -65,7 +69,7 @@ func TestDeleteMail_Success(t *testing.T) {
 			mData.EXPECT().DeleteMailsAfterDate(code.ConvertToDate()).Return(0, nil)
 
 			router := chi.NewRouter()
-			handler := handlers.DeleteMail(mData, logger)
+			handler := handlers.DeleteMail(mData, nil, logger)
 
 			router.Delete("/mail", handler)
 
@@ -81,3 +85,67 @@ func TestDeleteMail_Success(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteMail_PublishesEvent(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+	mData := new(mocks.MockMailRemover)
+	events := &testPublisher{}
+
+	mData.EXPECT().DeleteMailsAfterDate("").Return(3, nil)
+
+	handler := handlers.DeleteMail(mData, events, logger)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/mail?prune=all", nil)
+
+	handler(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+	assert.Equal(t, "3", recorder.Body.String())
+	assert.Equal(t, []model.MailEvent{model.NewMailPrunedEvent("all", 3)}, events.published)
+
+	mData.AssertExpectations(t)
+}
+
+func TestDeleteMailItem(t *testing.T) {
+	t.Parallel()
+
+	mailItem := model.MailItem{
+		ID:          uuid.Must(uuid.NewV4()),
+		FromAddress: "alice@x.test",
+		Subject:     "Deleted",
+	}
+
+	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+	mData := new(mocks.MockMailRemover)
+	events := &testPublisher{}
+
+	mData.EXPECT().DeleteMailByID(mailItem.ID).Return(nil)
+
+	handler := handlers.DeleteMailItem(mData, events, logger)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/mail/"+mailItem.ID.String(), nil)
+	request = request.WithContext(middleware.AttachMailItem(request.Context(), mailItem))
+
+	handler(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+
+	require.Len(t, events.published, 1)
+	assert.Equal(t, model.MailDeletedEvent, events.published[0].Type)
+	assert.Equal(t, mailItem.ID, events.published[0].Mail.ID)
+	assert.Equal(t, "Deleted", events.published[0].Mail.Subject)
+
+	mData.AssertExpectations(t)
+}
+
+type testPublisher struct {
+	published []model.MailEvent
+}
+
+func (p *testPublisher) Publish(event model.MailEvent) {
+	p.published = append(p.published, event)
+}
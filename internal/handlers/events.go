@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+// eventKeepAliveInterval is how often a comment is sent on an idle event stream, so proxies do not close it.
+const eventKeepAliveInterval = 30 * time.Second
+
+// MailEventPublisher pushes mail events to everyone following along.
+type MailEventPublisher interface {
+	Publish(model.MailEvent)
+}
+
+// MailEventSubscriber hands out the mail events from the time of subscribing.
+type MailEventSubscriber interface {
+	Subscribe() (<-chan model.MailEvent, func())
+}
+
+type MailEvents interface {
+	MailEventPublisher
+	MailEventSubscriber
+}
+
+// StreamMailEvents streams mail events to the client as server-sent events, named after the event type and carrying
+// the event as JSON. The stream lasts until the client disconnects. Events published while a client is not connected,
+// or while it is not keeping up, are not replayed. With authentication turned on the stream needs the API token like the
+// rest of the API. A browser EventSource cannot send the Authorization header, so the token may be passed in the token
+// query parameter instead.
+//
+// GET: /events
+func StreamMailEvents(
+	subscriber MailEventSubscriber,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := response.ValidContextsAndMethod(request, http.MethodGet); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		controller := http.NewResponseController(writer)
+
+		// subscribe before anything is sent, so that nothing is missed once the client sees the stream open
+		events, unsubscribe := subscriber.Subscribe()
+		defer unsubscribe()
+
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.Header().Set("X-Accel-Buffering", "no")
+		writer.WriteHeader(http.StatusOK)
+
+		if err := controller.Flush(); err != nil {
+			logger.Printf("Event stream cannot be flushed: %s", err)
+
+			return
+		}
+
+		keepAlive := time.NewTicker(eventKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			var err error
+
+			select {
+			case event := <-events:
+				err = writeMailEvent(writer, event)
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(writer, ": keep-alive\n\n")
+			case <-request.Context().Done():
+				return
+			}
+
+			if err == nil {
+				err = controller.Flush()
+			}
+
+			if err != nil {
+				logger.Printf("Event stream closed: %s", err)
+
+				return
+			}
+		}
+	}
+}
+
+// writeMailEvent writes a single mail event in the server-sent events format.
+func writeMailEvent(writer http.ResponseWriter, event model.MailEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: problem encoding %s event", err, event.Type)
+	}
+
+	_, err = fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, data)
+
+	return err
+}
@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestStreamMailEvents(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+	subscriber := newTestEventSubscriber()
+
+	server := httptest.NewServer(http.HandlerFunc(handlers.StreamMailEvents(subscriber, logger)))
+	t.Cleanup(server.Close)
+
+	response, err := http.Get(server.URL + "/events")
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode, "response code should match expected")
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	received := model.NewMailReceivedEvent(&model.MailItem{
+		ID:          uuid.Must(uuid.NewV4()),
+		ToAddresses: model.MailAddressCollection{"alice@x.test"},
+		Subject:     "Hello",
+	})
+
+	subscriber.events <- received
+	subscriber.events <- model.NewMailPrunedEvent("all", 2)
+
+	reader := bufio.NewReader(response.Body)
+
+	name, data := readServerSentEvent(t, reader)
+	assert.Equal(t, model.MailReceivedEvent, name)
+
+	var event model.MailEvent
+
+	require.NoError(t, json.Unmarshal([]byte(data), &event))
+	assert.Equal(t, received.Mail.ID, event.Mail.ID)
+	assert.Equal(t, "Hello", event.Mail.Subject)
+
+	name, data = readServerSentEvent(t, reader)
+	assert.Equal(t, model.MailPrunedEvent, name)
+	assert.JSONEq(t, `{"type":"mail.pruned","pruneCode":"all","count":2}`, data)
+}
+
+// readServerSentEvent reads the name and data of the next event on a stream.
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var name, data string
+
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+type testEventSubscriber struct {
+	events chan model.MailEvent
+}
+
+func newTestEventSubscriber() *testEventSubscriber {
+	return &testEventSubscriber{
+		events: make(chan model.MailEvent, 2),
+	}
+}
+
+func (s *testEventSubscriber) Subscribe() (<-chan model.MailEvent, func()) {
+	return s.events, func() {}
+}
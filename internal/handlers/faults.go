@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+)
+
+type FaultRuleStore interface {
+	Rules() slurperio.FaultConfig
+	SetRules(slurperio.FaultConfig) error
+}
+
+// GetFaultRules returns the fault injection rules in effect on the SMTP server.
+//
+// GET: /faults
+func GetFaultRules(
+	faults FaultRuleStore,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := response.ValidContextsAndMethod(request, http.MethodGet); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		renderFaultRules(writer, request, faults, logger)
+	}
+}
+
+// SetFaultRules replaces the fault injection rules on the SMTP server. The body holds the complete set of rules, so a
+// test can set up its own failure scenario without knowing what ran before it.
+//
+// PUT: /faults
+func SetFaultRules(
+	faults FaultRuleStore,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := response.ValidContextsAndMethod(request, http.MethodPut); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			err = fmt.Errorf("%w: failed to read request body", err)
+
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		var rules slurperio.FaultConfig
+		if err := json.Unmarshal(body, &rules); err != nil {
+			err = fmt.Errorf("%w: failed to read request body", err)
+
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		if err := faults.SetRules(rules); err != nil {
+			err = fmt.Errorf("%w: %w", response.ErrInvalidInput, err)
+
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		logger.Printf("Fault injection rules updated")
+		renderFaultRules(writer, request, faults, logger)
+	}
+}
+
+// ClearFaultRules turns off all fault injection on the SMTP server.
+//
+// DELETE: /faults
+func ClearFaultRules(
+	faults FaultRuleStore,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := response.ValidContextsAndMethod(request, http.MethodDelete); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		if err := faults.SetRules(slurperio.FaultConfig{}); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+			return
+		}
+
+		logger.Printf("Fault injection rules cleared")
+		renderFaultRules(writer, request, faults, logger)
+	}
+}
+
+func renderFaultRules(writer http.ResponseWriter, request *http.Request, faults FaultRuleStore, logger *log.Logger) {
+	response.RenderOrLog(writer, request, &response.JSONResponse{
+		HTTPStatusCode: http.StatusOK,
+		Value: &response.FaultRulesResponse{
+			FaultConfig: faults.Rules(),
+		},
+	}, logger)
+}
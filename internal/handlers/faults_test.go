@@ -0,0 +1,55 @@
+package handlers_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/internal/smtp"
+)
+
+func TestFaultRules(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+	faults := smtp.NewFaultInjector(slurperio.FaultConfig{})
+
+	router := chi.NewRouter()
+	router.Get("/faults", handlers.GetFaultRules(faults, logger))
+	router.Put("/faults", handlers.SetFaultRules(faults, logger))
+	router.Delete("/faults", handlers.ClearFaultRules(faults, logger))
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, "/faults", strings.NewReader(body))
+
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	recorder := serve(http.MethodPut, `{"rcpt":{"percent":25,"code":451},"recipients":[{"pattern":"*@bounce.test","code":550}]}`)
+	assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+	assert.Contains(t, recorder.Body.String(), `"pattern":"*@bounce.test"`)
+	assert.Equal(t, 25.0, faults.Rules().Rcpt.Percent)
+
+	recorder = serve(http.MethodPut, `{"data":{"percent":10,"code":250}}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "response code should match expected")
+	assert.Contains(t, recorder.Body.String(), `invalid input`)
+
+	// invalid rules leave the previous ones in place
+	recorder = serve(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+	assert.Contains(t, recorder.Body.String(), `"percent":25`)
+
+	recorder = serve(http.MethodDelete, "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+	assert.Empty(t, faults.Rules().Recipients)
+}
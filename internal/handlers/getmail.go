@@ -3,33 +3,50 @@ package handlers
 import (
 	"encoding/base64"
 	"fmt"
+	"html"
 	"log"
 	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/adampresley/webframework/sanitizer"
 	"github.com/gofrs/uuid"
 
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/requests"
 	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/io"
 	"github.com/mailslurper/mailslurper/v2/internal/model"
 	"github.com/mailslurper/mailslurper/v2/internal/persistence"
 )
 
+// sinceSettleTime is how long newly received mail is left out of since listings. Mail is given its received time before
+// it is committed, so for a moment it may still be committing behind mail received after it.
+const sinceSettleTime = 2 * time.Second
+
+// contentIDReference matches a cid: URL as written in an HTML attribute or style (RFC 2392).
+var contentIDReference = regexp.MustCompile(`(?i)cid:[^"'\s)>]+`)
+
 type MailCollectionGetter interface {
 	MailCounter
 	GetMailCollection(int, int, *persistence.MailSearch) ([]model.MailItem, error)
 }
 
 type MailMessageRawGetter interface {
-	GetMailMessageRawByID(uuid.UUID) (string, error)
+	GetMailMessageRawByID(uuid.UUID) ([]byte, error)
 }
 
 type GetMailCollectionParams struct {
 	PageNumber string `form:"pageNumber,omitempty" json:"pageNumber,omitempty"`
+	PageSize   string `form:"pageSize,omitempty" json:"pageSize,omitempty"`
 	Message    string `form:"message,omitempty" json:"message,omitempty"`
+	Subject    string `form:"subject,omitempty" json:"subject,omitempty"`
 	Start      string `form:"start,omitempty" json:"start,omitempty"`
 	End        string `form:"end,omitempty" json:"end,omitempty"`
 	From       string `form:"from,omitempty" json:"from,omitempty"`
@@ -37,14 +54,30 @@ type GetMailCollectionParams struct {
 
 	OrderByField     string `form:"orderby,omitempty" json:"orderby,omitempty"`
 	OrderByDirection string `form:"dir,omitempty" json:"dir,omitempty"`
+
+	// Cursor and Since switch to cursor pagination when given, even if empty.
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+	Since  *string `form:"since,omitempty" json:"since,omitempty"`
 }
 
-// GetMailCollection returns a collection of mail items. This is constrianed by a page number. A page of data contains
-// 50 items.
+// GetMailCollection returns a collection of mail items. This is constrianed by a page number. A page holds pageSize
+// items unless the request asks for a different size, up to io.MaxPageSize.
+//
+// Mail can be filtered by header, either with "header:Name=value" terms in the message search or with one or more
+// header=Name=value parameters.
+//
+// Instead of page numbers, mail can be paged through with opaque cursors, which stay put as new mail arrives. An empty
+// cursor parameter starts with the newest mail, and each page returns the cursor of the next, older page while there
+// may be one. The since parameter instead returns the mail received after a cursor, oldest first, and always returns the
+// cursor to poll with next. An empty since starts with the oldest mail. Mail received in the last couple of seconds is
+// left for the next poll, so that a poll never moves past mail that is still being stored.
 //
-// GET: /mails?pageNumber={pageNumber}
+// GET: /mails?pageNumber={pageNumber}&pageSize={pageSize}
+// GET: /mails?cursor={cursor}&pageSize={pageSize}
+// GET: /mails?since={cursor}&pageSize={pageSize}
 func GetMailCollection(
 	data MailCollectionGetter,
+	pageSize int,
 	logger *log.Logger,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -60,7 +93,7 @@ func GetMailCollection(
 		var totalRecordCount int
 
 		/*
-		 * Validate incoming arguments. A page is currently 50 items, hard coded
+		 * Validate incoming arguments
 		 */
 		if params.PageNumber == "" {
 			pageNumber = 1
@@ -74,23 +107,73 @@ func GetMailCollection(
 			}
 		}
 
-		length := 50
+		if pageNumber < 1 {
+			err = fmt.Errorf("Invalid page number passed to GetMailCollection - %d", pageNumber)
+
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		length := pageSize
+		if params.PageSize != "" {
+			if length, err = strconv.Atoi(params.PageSize); err != nil || length < 1 || length > io.MaxPageSize {
+				err = fmt.Errorf("Invalid page size passed to GetMailCollection - %s", params.PageSize)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+		}
+
 		offset := (pageNumber - 1) * length
 
 		/*
 		 * Retrieve mail items
 		 */
+		message, headers := persistence.ParseMessageSearch(params.Message)
+
+		for _, term := range request.URL.Query()[requests.HeaderQueryParam] {
+			if header, ok := persistence.ParseHeaderSearch(term); ok {
+				headers = append(headers, header)
+			}
+		}
+
 		mailSearch := &persistence.MailSearch{
-			Message: params.Message,
+			Message: message,
+			Subject: params.Subject,
 			Start:   params.Start,
 			End:     params.End,
 			From:    params.From,
 			To:      params.To,
+			Headers: headers,
 
 			OrderByField:     params.OrderByField,
 			OrderByDirection: params.OrderByDirection,
 		}
 
+		if params.Cursor != nil {
+			if mailSearch.Cursor, err = persistence.ParseMailCursor(*params.Cursor); err != nil {
+				err = fmt.Errorf("%w: cursor passed to GetMailCollection - %s", err, *params.Cursor)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+		}
+
+		if params.Since != nil {
+			if mailSearch.Since, err = persistence.ParseMailCursor(*params.Since); err != nil {
+				err = fmt.Errorf("%w: since cursor passed to GetMailCollection - %s", err, *params.Since)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+
+			mailSearch.ReceivedBefore = time.Now().Add(-sinceSettleTime)
+		}
+
 		if mailCollection, err = data.GetMailCollection(offset, length, mailSearch); err != nil {
 			err = fmt.Errorf("%w: problem getting mail collection", err)
 
@@ -107,10 +190,7 @@ func GetMailCollection(
 			return
 		}
 
-		totalPages := int(math.Ceil(float64(totalRecordCount / length)))
-		if totalPages*length < totalRecordCount {
-			totalPages++
-		}
+		totalPages := int(math.Ceil(float64(totalRecordCount) / float64(length)))
 
 		logger.Printf("Mail collection page %d retrieved", pageNumber)
 
@@ -120,11 +200,25 @@ func GetMailCollection(
 				MailItems:    mailCollection,
 				TotalPages:   totalPages,
 				TotalRecords: totalRecordCount,
+				NextCursor:   nextCursor(mailSearch, mailCollection, length),
 			},
 		}, logger)
 	}
 }
 
+// nextCursor returns the cursor to continue a cursor search with. A search by Cursor has no next cursor once a page
+// comes back short, while a search by Since always has one, so that it can be polled.
+func nextCursor(mailSearch *persistence.MailSearch, mailCollection []model.MailItem, length int) string {
+	switch {
+	case mailSearch.Since != nil && len(mailCollection) == 0:
+		return mailSearch.Since.String()
+	case mailSearch.Since != nil, mailSearch.Cursor != nil && len(mailCollection) == length:
+		return persistence.NewMailCursor(&mailCollection[len(mailCollection)-1]).String()
+	default:
+		return ""
+	}
+}
+
 // GetMail returns a single mail item by ID.
 //
 // GET: /mail/{mailId}
@@ -140,12 +234,6 @@ func GetMail(
 			return
 		}
 
-		mailBody, convertSucess := convertFromBase64(mailItem.Body)
-		if convertSucess == true {
-			mailItem.Body = mailBody
-			mailItem.HTMLBody = mailBody
-		}
-
 		logger.Printf("Mail item %s retrieved", mailItem.ID)
 		response.RenderOrLog(writer, request, &response.JSONResponse{
 			HTTPStatusCode: http.StatusOK,
@@ -154,10 +242,14 @@ func GetMail(
 	}
 }
 
-// GetMailMessage returns the message contents of a single mail item
+// GetMailMessage returns the message contents of a single mail item for display. Mail without an HTML alternative
+// has its plain text converted to HTML here, as bodies are stored as sent. In the HTML alternative, cid: references to
+// inline images are embedded as data URLs, and other inline parts are pointed at the attachment download on this API.
+// PreviewMail serves the HTML unaltered instead.
 //
 // GET: /mail/{mailId}/message
 func GetMailMessage(
+	xss sanitizer.IXSSServiceProvider,
 	logger *log.Logger,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -168,30 +260,113 @@ func GetMailMessage(
 			return
 		}
 
-		mailBody, convertSucess := convertFromBase64(mailItem.Body)
-		if convertSucess == true {
-			response.RenderOrLog(writer, request, &response.HTMLResponse{
-				HTTPStatusCode: http.StatusOK,
-				Value:          mailBody,
-			}, logger)
-
-			return
+		var body string
+
+		switch {
+		case mailItem.HTMLBody != "":
+			// the sanitizer only keeps web URLs, so inline images are embedded as data URLs once the html is clean
+			baseURL := path.Dir(request.URL.Path)
+			body = xss.SanitizeString(resolveContentIDs(mailItem.HTMLBody, baseURL, mailItem.Attachments))
+			body = embedInlineImages(body, baseURL, mailItem.Attachments)
+		case mailItem.TextBody != "":
+			body = textToHTML(mailItem.TextBody)
+		default:
+			// mail stored before the alternatives were kept has neither
+			body = xss.SanitizeString(mailItem.Body)
 		}
 
 		logger.Printf("Mail item %s retrieved", mailItem.ID)
 		response.RenderOrLog(writer, request, &response.HTMLResponse{
 			HTTPStatusCode: http.StatusOK,
-			Value:          mailItem.Body,
+			Value:          body,
 		}, logger)
 	}
 }
 
-// GetMailMessageRaw returns the message contents of a single mail item
+// GetMailMessageRaw returns the message of a single mail item exactly as it was received, as plain text.
 //
 // GET: /mail/{mailId}/messageraw
 func GetMailMessageRaw(
 	data MailMessageRawGetter,
 	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		raw, ok := getRawMessage(writer, request, data, logger)
+		if !ok {
+			return
+		}
+
+		response.RenderOrLog(writer, request, response.NewTextResponse(http.StatusOK, raw), logger)
+	}
+}
+
+// DownloadMailMessageRaw streams the message of a single mail item exactly as it was received, so it can be opened in
+// a mail client or replayed.
+//
+// GET: /mail/{mailId}/raw.eml
+func DownloadMailMessageRaw(
+	data MailMessageRawGetter,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		raw, ok := getRawMessage(writer, request, data, logger)
+		if !ok {
+			return
+		}
+
+		mailItem := middleware.GetMailItem(request.Context())
+
+		response.RenderOrLog(writer, request, &response.DataResponse{
+			HTTPStatusCode: http.StatusOK,
+			Data:           raw,
+			ContentType:    "message/rfc822",
+			FileName:       mailItem.ID.String() + ".eml",
+		}, logger)
+	}
+}
+
+// getRawMessage loads the raw message of the mail item in the request context. An error response is rendered and false
+// returned if it cannot be loaded, or if the mail item was stored before raw messages were kept.
+func getRawMessage(
+	writer http.ResponseWriter,
+	request *http.Request,
+	data MailMessageRawGetter,
+	logger *log.Logger,
+) ([]byte, bool) {
+	mailItem := middleware.GetMailItem(request.Context())
+	if err := response.ValidContextsAndMethod(request, http.MethodGet, mailItem); err != nil {
+		response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+		return nil, false
+	}
+
+	raw, err := data.GetMailMessageRawByID(mailItem.ID)
+	if err != nil {
+		err = fmt.Errorf("%w: Problem getting raw message for mail item %s", err, mailItem.ID)
+
+		response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+		return nil, false
+	}
+
+	if raw == nil {
+		err = fmt.Errorf("%w: raw message for mail item %s", response.ErrNotFound, mailItem.ID)
+
+		response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+		return nil, false
+	}
+
+	logger.Printf("Raw message for mail item %s retrieved", mailItem.ID)
+
+	return raw, true
+}
+
+// GetMailText returns the plain text alternative of a single mail item as sent, converted to UTF-8.
+//
+// GET: /mail/{mailId}/text
+func GetMailText(
+	logger *log.Logger,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		mailItem := middleware.GetMailItem(request.Context())
@@ -201,26 +376,109 @@ func GetMailMessageRaw(
 			return
 		}
 
-		/*
-		* Retrieve the mail item
-		 */
-		body, err := data.GetMailMessageRawByID(mailItem.ID)
-		if err != nil {
-			err = fmt.Errorf("%w: Problem getting mail item %s in GetMailMessageRaw", err, mailItem.ID)
+		if mailItem.TextBody == "" {
+			err := fmt.Errorf("%w: text body for mail item %s", response.ErrNotFound, mailItem.ID)
 
-			response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+			response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
 
 			return
 		}
 
-		logger.Printf("Mail item %s retrieved", mailItem.ID)
+		logger.Printf("Mail item %s text body retrieved", mailItem.ID)
+		response.RenderOrLog(writer, request, response.NewTextResponse(http.StatusOK, []byte(mailItem.TextBody)), logger)
+	}
+}
+
+// GetMailHTML returns the HTML alternative of a single mail item as sent, converted to UTF-8. The HTML is not
+// sanitized, so the response carries the same sandbox policy as the preview, with remote content blocked.
+//
+// GET: /mail/{mailId}/html
+func GetMailHTML(
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		mailItem := middleware.GetMailItem(request.Context())
+		if err := response.ValidContextsAndMethod(request, http.MethodGet, mailItem); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		if mailItem.HTMLBody == "" {
+			err := fmt.Errorf("%w: html body for mail item %s", response.ErrNotFound, mailItem.ID)
+
+			response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+			return
+		}
+
+		logger.Printf("Mail item %s html body retrieved", mailItem.ID)
+
+		setPreviewHeaders(writer, previewPolicy(false))
 		response.RenderOrLog(writer, request, &response.HTMLResponse{
 			HTTPStatusCode: http.StatusOK,
-			Value:          body,
+			Value:          mailItem.HTMLBody,
 		}, logger)
 	}
 }
 
+// textToHTML escapes plain text and keeps its line breaks, for display in an HTML view.
+func textToHTML(text string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br />\n")
+}
+
+// resolveContentIDs replaces cid: references in an HTML body with the download URL of the attachment carrying that
+// Content-ID. References to parts that were not stored are left as they are.
+func resolveContentIDs(body, baseURL string, attachments []model.Attachment) string {
+	return replaceContentIDs(body, attachments, func(attachment model.Attachment) string {
+		return baseURL + "/attachment/" + attachment.ID.String()
+	})
+}
+
+// embedInlineImages replaces the image sources resolved by resolveContentIDs with the contents of the image as a data URL.
+// The UI shows the message through srcdoc, and the images would otherwise be loaded without the API token. Inline
+// parts that are not images keep their download URL.
+func embedInlineImages(body, baseURL string, attachments []model.Attachment) string {
+	for _, attachment := range attachments {
+		mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+		if attachment.ContentID == "" || !strings.HasPrefix(mediaType, "image/") {
+			continue
+		}
+
+		source := `src="` + baseURL + "/attachment/" + attachment.ID.String() + `"`
+		body = strings.ReplaceAll(body, source, `src="`+attachmentDataURL(attachment)+`"`)
+	}
+
+	return body
+}
+
+// replaceContentIDs replaces cid: references in an HTML body with the replacement for the attachment carrying that
+// Content-ID. References to parts that were not stored are left as they are.
+func replaceContentIDs(body string, attachments []model.Attachment, replacement func(model.Attachment) string) string {
+	byContentID := make(map[string]model.Attachment, len(attachments))
+
+	for _, attachment := range attachments {
+		if attachment.ContentID != "" {
+			byContentID[strings.ToLower(attachment.ContentID)] = attachment
+		}
+	}
+
+	return contentIDReference.ReplaceAllStringFunc(body, func(reference string) string {
+		contentID := reference[len("cid:"):]
+		if unescaped, err := url.PathUnescape(contentID); err == nil {
+			contentID = unescaped
+		}
+
+		if attachment, ok := byContentID[strings.ToLower(contentID)]; ok {
+			return replacement(attachment)
+		}
+
+		return reference
+	})
+}
+
 // DownloadAttachment retrieves binary database from storage and streams it back to the caller.
 //
 // GET: /mail/{mailID}/attachment/{attachmentID}
@@ -255,21 +513,17 @@ func DownloadAttachment(
 			data = []byte(attachment.Contents)
 		}
 
+		fileName := attachment.FileName
+		if fileName == "" {
+			fileName = attachment.ID.String()
+		}
+
 		logger.Printf("Attachment %s retrieved", attachment.ID)
 		response.RenderOrLog(writer, request, &response.DataResponse{
 			HTTPStatusCode: http.StatusOK,
 			Data:           data,
+			ContentType:    attachment.ContentType,
+			FileName:       fileName,
 		}, logger)
 	}
 }
-
-func convertFromBase64(s string) (string, bool) {
-	var mailBody []byte
-	var err error
-
-	if mailBody, err = base64.StdEncoding.DecodeString(strings.Replace(s, " ", "", -1)); err == nil {
-		return string(mailBody[:]), true
-	}
-
-	return "", false
-}
@@ -0,0 +1,315 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adampresley/webframework/sanitizer"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/mocks"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+func TestDownloadMailMessageRaw(t *testing.T) {
+	t.Parallel()
+
+	mailID := uuid.Must(uuid.NewV4())
+	raw := []byte("Subject: Raw\r\n\r\nbody\r\n")
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		code     int
+		expected string
+	}{
+		{name: "found", raw: raw, code: http.StatusOK, expected: string(raw)},
+		{name: "not stored", raw: nil, code: http.StatusNotFound, expected: `not found`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			mData := new(mocks.MockPersistance)
+
+			mData.EXPECT().GetMailMessageRawByID(mailID).Return(test.raw, nil)
+
+			handler := handlers.DownloadMailMessageRaw(mData, logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail/"+mailID.String()+"/raw.eml", nil)
+			request = request.WithContext(middleware.AttachMailItem(request.Context(), model.MailItem{ID: mailID}))
+
+			handler(recorder, request)
+
+			assert.Equal(t, test.code, recorder.Code, "response code should match expected")
+			assert.Contains(t, recorder.Body.String(), test.expected)
+
+			if test.code == http.StatusOK {
+				assert.Equal(t, "message/rfc822", recorder.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename=`+mailID.String()+`.eml`, recorder.Header().Get("Content-Disposition"))
+			}
+
+			mData.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetMailCollection_PageSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		query  string
+		code   int
+		offset int
+		length int
+		pages  int
+	}{
+		{name: "configured size", query: "?pageNumber=2", code: http.StatusOK, offset: 25, length: 25, pages: 3},
+		{name: "requested size", query: "?pageNumber=3&pageSize=10", code: http.StatusOK, offset: 20, length: 10, pages: 6},
+		{name: "last page partly filled", query: "?pageSize=50", code: http.StatusOK, offset: 0, length: 50, pages: 2},
+		{name: "last page filled", query: "?pageSize=11", code: http.StatusOK, offset: 0, length: 11, pages: 5},
+		{name: "size too large", query: "?pageSize=501", code: http.StatusBadRequest},
+		{name: "size too small", query: "?pageSize=0", code: http.StatusBadRequest},
+		{name: "page too small", query: "?pageNumber=0", code: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			mData := new(mocks.MockPersistance)
+
+			if test.code == http.StatusOK {
+				mData.EXPECT().GetMailCollection(test.offset, test.length, mock.Anything).Return([]model.MailItem{}, nil)
+				mData.EXPECT().GetMailCount(mock.Anything).Return(55, nil)
+			}
+
+			handler := handlers.GetMailCollection(mData, 25, logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail"+test.query, nil)
+
+			handler(recorder, request)
+
+			assert.Equal(t, test.code, recorder.Code, "response code should match expected")
+
+			if test.code == http.StatusOK {
+				assert.Contains(t, recorder.Body.String(), fmt.Sprintf(`"totalPages":%d`, test.pages))
+			}
+
+			mData.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetMailCollection_Cursor(t *testing.T) {
+	t.Parallel()
+
+	first := model.MailItem{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now().Add(-time.Minute)}
+	second := model.MailItem{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now()}
+	since := persistence.NewMailCursor(&first).String()
+
+	tests := []struct {
+		name     string
+		query    string
+		items    []model.MailItem
+		code     int
+		expected string
+	}{
+		{
+			name:     "full page",
+			query:    "?cursor=&pageSize=2",
+			items:    []model.MailItem{second, first},
+			code:     http.StatusOK,
+			expected: persistence.NewMailCursor(&first).String(),
+		},
+		{
+			name:  "last page",
+			query: "?cursor=&pageSize=2",
+			items: []model.MailItem{second},
+			code:  http.StatusOK,
+		},
+		{
+			name:     "new mail",
+			query:    "?since=" + since,
+			items:    []model.MailItem{second},
+			code:     http.StatusOK,
+			expected: persistence.NewMailCursor(&second).String(),
+		},
+		{
+			name:     "no new mail",
+			query:    "?since=" + since,
+			items:    []model.MailItem{},
+			code:     http.StatusOK,
+			expected: since,
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=not-a-cursor",
+			code:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			mData := new(mocks.MockPersistance)
+
+			// only since listings hold back the mail that may still be committing
+			settles := mock.MatchedBy(func(search *persistence.MailSearch) bool {
+				return (search.Since != nil) == !search.ReceivedBefore.IsZero()
+			})
+
+			if test.code == http.StatusOK {
+				mData.EXPECT().GetMailCollection(0, mock.Anything, settles).Return(test.items, nil)
+				mData.EXPECT().GetMailCount(mock.Anything).Return(2, nil)
+			}
+
+			handler := handlers.GetMailCollection(mData, 25, logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail"+test.query, nil)
+
+			handler(recorder, request)
+
+			assert.Equal(t, test.code, recorder.Code, "response code should match expected")
+
+			if test.code == http.StatusOK {
+				var body response.MailCollectionResponse
+
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+				assert.Equal(t, test.expected, body.NextCursor)
+			}
+
+			mData.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetMailAlternatives(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		handler  func(*log.Logger) func(http.ResponseWriter, *http.Request)
+		mailItem model.MailItem
+		code     int
+		expected string
+	}{
+		{
+			name:     "text",
+			handler:  handlers.GetMailText,
+			mailItem: model.MailItem{TextBody: "café\r\n<b>", HTMLBody: "<p>café</p>"},
+			code:     http.StatusOK,
+			expected: "café\r\n<b>",
+		},
+		{
+			name:     "html",
+			handler:  handlers.GetMailHTML,
+			mailItem: model.MailItem{TextBody: "café", HTMLBody: "<p>café</p><script>alert(1)</script>"},
+			code:     http.StatusOK,
+			expected: "<p>café</p><script>alert(1)</script>",
+		},
+		{
+			name:     "missing text",
+			handler:  handlers.GetMailText,
+			mailItem: model.MailItem{HTMLBody: "<p>café</p>"},
+			code:     http.StatusNotFound,
+			expected: "not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			handler := test.handler(logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request = request.WithContext(middleware.AttachMailItem(request.Context(), test.mailItem))
+
+			handler(recorder, request)
+
+			assert.Equal(t, test.code, recorder.Code, "response code should match expected")
+			assert.Contains(t, recorder.Body.String(), test.expected)
+		})
+	}
+}
+
+func TestGetMailMessage(t *testing.T) {
+	t.Parallel()
+
+	imageID := uuid.Must(uuid.FromString("0b6f0a1e-4a44-4cf4-9a40-0f8ea1d0d8c5"))
+	notesID := uuid.Must(uuid.FromString("5d1c8f0e-2b7a-4f1e-8a59-3c6f1d2e9b40"))
+
+	tests := []struct {
+		name     string
+		mailItem model.MailItem
+		expected string
+	}{
+		{
+			name:     "plain text is converted for display",
+			mailItem: model.MailItem{Body: "line <one>\r\nline two", TextBody: "line <one>\r\nline two"},
+			expected: "line &lt;one&gt;<br />\nline two",
+		},
+		{
+			name:     "html is shown as is",
+			mailItem: model.MailItem{Body: "<p>html</p>", TextBody: "text", HTMLBody: "<p>html</p>"},
+			expected: "<p>html</p>",
+		},
+		{
+			name: "inline parts are resolved and html is sanitized",
+			mailItem: model.MailItem{
+				HTMLBody: `<img src="cid:Logo@example.com"><img src="cid:missing@example.com"><script>alert(1)</script>` +
+					`<a href="cid:notes@example.com">notes</a>`,
+				Attachments: []model.Attachment{
+					{ID: imageID, ContentType: "image/png", ContentID: "logo@example.com", Inline: true, Contents: "iVBORw0KGgo="},
+					{ID: notesID, ContentType: "text/plain", ContentID: "notes@example.com", Inline: true, Contents: "notes"},
+				},
+			},
+			// images are embedded, as the UI shows the message through srcdoc and cannot send the token for them
+			expected: `<img src="data:image/png;base64,iVBORw0KGgo=">` +
+				`<a href="/mail/1234/attachment/` + notesID.String() + `" rel="nofollow">notes</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			handler := handlers.GetMailMessage(sanitizer.NewXSSService(), logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail/1234/message", nil)
+			request = request.WithContext(middleware.AttachMailItem(request.Context(), test.mailItem))
+
+			handler(recorder, request)
+
+			assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+			assert.Equal(t, test.expected, recorder.Body.String())
+		})
+	}
+}
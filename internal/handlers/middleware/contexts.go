@@ -11,6 +11,7 @@ type contextKey int
 const (
 	ctxMailItemKey contextKey = iota
 	ctxMailItemAttachmentKey
+	ctxMailItemPartKey
 	ctxUserKey
 )
 
@@ -54,6 +55,26 @@ func GetMailItemAttachment(ctx context.Context) *model.Attachment {
 	return &item
 }
 
+// AttachMailPart ...
+func AttachMailPart(ctx context.Context, part model.MessagePart) context.Context {
+	return context.WithValue(ctx, ctxMailItemPartKey, part)
+}
+
+// GetMailPart ...
+func GetMailPart(ctx context.Context) *model.MessagePart {
+	val := ctx.Value(ctxMailItemPartKey)
+	if val == nil {
+		return nil
+	}
+
+	part, ok := val.(model.MessagePart)
+	if !ok {
+		return nil
+	}
+
+	return &part
+}
+
 func AttachUser(ctx context.Context, user string) context.Context {
 	return context.WithValue(ctx, ctxUserKey, user)
 }
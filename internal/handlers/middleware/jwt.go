@@ -17,6 +17,12 @@ import (
 	"github.com/mailslurper/mailslurper/v2/pkg/contexts"
 )
 
+// EventStreamTokenParam is the query parameter carrying the API token of an event stream. A browser EventSource cannot
+// set the Authorization header.
+const EventStreamTokenParam = "token"
+
+// JWTAuth refuses requests without a valid API token, unless authentication is turned off. The token is read from the
+// Authorization header, or from the EventStreamTokenParam query parameter of a request for an event stream.
 func JWTAuth(
 	config *io.Config,
 	jwtService *slurperjwt.JWTService,
@@ -26,11 +32,13 @@ func JWTAuth(
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			if config.AuthenticationScheme == authscheme.NONE {
 				next.ServeHTTP(writer, request.WithContext(AttachUser(request.Context(), "")))
+
+				return
 			}
 
 			logger.Print("Starting parse of JWT token")
 
-			sToken := tokenFromHeader(request)
+			sToken := tokenFromRequest(request)
 			if sToken == "" {
 				err := fmt.Errorf("No bearer and token in authorization header")
 
@@ -117,6 +125,15 @@ func echoTokenFromHeader(ctx echo.Context) string {
 	return strings.TrimPrefix(ctx.Request().Header.Get("Authorization"), "Bearer ")
 }
 
-func tokenFromHeader(request *http.Request) string {
-	return strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+func tokenFromRequest(request *http.Request) string {
+	if token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer "); token != "" {
+		return token
+	}
+
+	// the query parameter is limited to event streams, to keep tokens out of the URLs of everything else
+	if request.Header.Get("Accept") == "text/event-stream" {
+		return request.URL.Query().Get(EventStreamTokenParam)
+	}
+
+	return ""
 }
@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
+	"github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/pkg/auth/authscheme"
+	slurperjwt "github.com/mailslurper/mailslurper/v2/pkg/auth/jwt"
+)
+
+func TestJWTAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		scheme        string
+		target        string
+		accept        string
+		authorization string
+		expectedCalls int
+		expectedCode  int
+		expectedError string
+	}{
+		{
+			name:          "authentication disabled",
+			scheme:        authscheme.NONE,
+			expectedCalls: 1,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "missing token",
+			scheme:        authscheme.BASIC,
+			expectedCalls: 0,
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid token",
+			scheme:        authscheme.BASIC,
+			authorization: "Bearer bm90IGEgdG9rZW4=",
+			expectedCalls: 0,
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Error parsing JWT token",
+		},
+		{
+			name:          "token in the query of an event stream",
+			scheme:        authscheme.BASIC,
+			target:        "/events?token=bm90IGEgdG9rZW4%3D",
+			accept:        "text/event-stream",
+			expectedCalls: 0,
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Error parsing JWT token",
+		},
+		{
+			name:          "token in the query of other requests",
+			scheme:        authscheme.BASIC,
+			target:        "/mail?token=bm90IGEgdG9rZW4%3D",
+			expectedCalls: 0,
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "No bearer and token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &io.Config{
+				AuthenticationScheme: test.scheme,
+				AuthSecret:           "secret",
+				AuthSalt:             "salt",
+			}
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+
+			var calls int
+
+			nextHandler := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				calls++
+
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			handler := middleware.JWTAuth(config, &slurperjwt.JWTService{Config: config}, logger)(nextHandler)
+
+			recorder := httptest.NewRecorder()
+			target := test.target
+			if target == "" {
+				target = "/mail"
+			}
+
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+
+			if test.accept != "" {
+				request.Header.Set("Accept", test.accept)
+			}
+
+			handler.ServeHTTP(recorder, request)
+
+			// a request is either passed on once or refused, never both
+			assert.Equal(t, test.expectedCalls, calls)
+			assert.Equal(t, test.expectedCode, recorder.Code, "response code should match expected")
+			assert.Contains(t, recorder.Body.String(), test.expectedError)
+		})
+	}
+}
@@ -43,6 +43,14 @@ func MailCtx(
 				return
 			}
 
+			if item == nil {
+				err = fmt.Errorf("%w: mail item %s", response.ErrNotFound, mailID)
+
+				response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+				return
+			}
+
 			ctx := AttachMailItem(request.Context(), *item)
 
 			next.ServeHTTP(writer, request.WithContext(ctx))
@@ -88,9 +96,70 @@ func MailAttachmentCtx(
 				return
 			}
 
+			if item == nil {
+				err = fmt.Errorf("%w: attachment %s", response.ErrNotFound, attachmentID)
+
+				response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+				return
+			}
+
 			ctx := AttachMailAttachment(request.Context(), *item)
 
 			next.ServeHTTP(writer, request.WithContext(ctx))
 		})
 	}
 }
+
+type MailPartGetter interface {
+	GetMessagePart(uuid.UUID, uuid.UUID) (*model.MessagePart, error)
+}
+
+// MailPartCtx ...
+func MailPartCtx(
+	data MailPartGetter,
+	pFn func(*http.Request, string) string,
+	logger *log.Logger,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			mailItem := GetMailItem(request.Context())
+			if err := response.ValidContextsAndMethod(request, http.MethodGet, mailItem); err != nil {
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+
+			partID, err := uuid.FromString(pFn(request, requests.MailPartIDPathParam))
+			if err != nil {
+				err := fmt.Errorf("%w: part id", response.ErrNotFound)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+
+			// retrieve the message part
+			part, err := data.GetMessagePart(mailItem.ID, partID)
+			if err != nil {
+				err = fmt.Errorf("%w: Problem getting message part %s", err, partID)
+
+				response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+				return
+			}
+
+			if part == nil {
+				err = fmt.Errorf("%w: message part %s", response.ErrNotFound, partID)
+
+				response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+				return
+			}
+
+			ctx := AttachMailPart(request.Context(), *part)
+
+			next.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+type MailPartTreeGetter interface {
+	GetMessagePartTree(uuid.UUID) (*model.MessagePart, error)
+}
+
+// GetMailParts returns the MIME part tree of a single mail item. Each part carries its ID, which can be used to
+// download the part.
+//
+// GET: /mail/{mailId}/parts
+func GetMailParts(
+	data MailPartTreeGetter,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		mailItem := middleware.GetMailItem(request.Context())
+		if err := response.ValidContextsAndMethod(request, http.MethodGet, mailItem); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		tree, err := data.GetMessagePartTree(mailItem.ID)
+		if err != nil {
+			err = fmt.Errorf("%w: Problem getting message parts for mail item %s", err, mailItem.ID)
+
+			response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+			return
+		}
+
+		if tree == nil {
+			err = fmt.Errorf("%w: message parts for mail item %s", response.ErrNotFound, mailItem.ID)
+
+			response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+			return
+		}
+
+		logger.Printf("Message parts for mail item %s retrieved", mailItem.ID)
+		response.RenderOrLog(writer, request, &response.JSONResponse{
+			HTTPStatusCode: http.StatusOK,
+			Value:          tree,
+		}, logger)
+	}
+}
+
+// DownloadMailPart streams the content of a single message part back to the caller. Leaf parts are sent with their
+// transfer encoding removed and multipart parts as received. The part is always sent as a download so that HTML parts
+// are never rendered in the context of the API.
+//
+// GET: /mail/{mailId}/parts/{partId}
+func DownloadMailPart(
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		part := middleware.GetMailPart(request.Context())
+		if err := response.ValidContextsAndMethod(request, http.MethodGet, part); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		contentType := part.ContentType
+		if contentType != "" && part.Charset != "" {
+			contentType = mime.FormatMediaType(contentType, map[string]string{"charset": part.Charset})
+		}
+
+		logger.Printf("Message part %s retrieved", part.ID)
+		response.RenderOrLog(writer, request, &response.DataResponse{
+			HTTPStatusCode: http.StatusOK,
+			Data:           part.Content,
+			ContentType:    contentType,
+			FileName:       partFileName(part),
+		}, logger)
+	}
+}
+
+// partFileName returns the file name given in the part headers, or one made from the part ID and content type.
+func partFileName(part *model.MessagePart) string {
+	if part.FileName != "" {
+		return part.FileName
+	}
+
+	extension := ".bin"
+	if extensions, err := mime.ExtensionsByType(part.ContentType); err == nil && len(extensions) > 0 {
+		extension = extensions[0]
+	}
+
+	return part.ID.String() + extension
+}
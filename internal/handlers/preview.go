@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/requests"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+var (
+	// headStartTag matches the start tag of the head element, but not of header.
+	headStartTag = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+
+	// doctype matches a doctype at the start of a document.
+	doctype = regexp.MustCompile(`(?i)^\s*<!doctype[^>]*>`)
+)
+
+type PreviewMailParams struct {
+	RemoteImages bool `form:"remoteImages,omitempty" json:"remoteImages,omitempty"`
+}
+
+// PreviewMail returns the HTML of a single mail item as sent, for inspection in a browser. The HTML is not sanitized.
+// Instead everything but inline styles and inline parts of the message is blocked, and remote images may be allowed
+// per request. Inline parts are embedded as data URLs, and the policy is carried in a meta tag as well as the
+// response headers, so the document stands on its own when the UI fetches it with the API token and shows it in a
+// sandboxed frame through srcdoc.
+//
+// GET: /mail/{mailId}/preview?remoteImages={true|false}
+func PreviewMail(
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		mailItem := middleware.GetMailItem(request.Context())
+		if err := response.ValidContextsAndMethod(request, http.MethodGet, mailItem); err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		params, err := requests.APIQueryParams[PreviewMailParams](request)
+		if err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		// mail stored before the alternatives were kept has neither, and its body is stored ready for display
+		body := mailItem.Body
+
+		switch {
+		case mailItem.HTMLBody != "":
+			body = replaceContentIDs(mailItem.HTMLBody, mailItem.Attachments, attachmentDataURL)
+		case mailItem.TextBody != "":
+			body = textToHTML(mailItem.TextBody)
+		}
+
+		policy := previewPolicy(params.RemoteImages)
+		setPreviewHeaders(writer, policy)
+
+		logger.Printf("Mail item %s preview retrieved", mailItem.ID)
+		response.RenderOrLog(writer, request, &response.HTMLResponse{
+			HTTPStatusCode: http.StatusOK,
+			Value:          addPreviewMetaTags(body, policy),
+		}, logger)
+	}
+}
+
+// previewPolicy returns the content security policy directives for mail HTML that may also be given in a meta tag.
+// Inline parts are embedded as data URLs, so only remote images ever need more than that.
+func previewPolicy(remoteImages bool) []string {
+	images := []string{"data:"}
+	if remoteImages {
+		images = append(images, "http:", "https:")
+	}
+
+	return []string{
+		"default-src 'none'",
+		"img-src " + strings.Join(images, " "),
+		"style-src 'unsafe-inline'",
+		"font-src data:",
+		"base-uri 'none'",
+		"form-action 'none'",
+	}
+}
+
+// addPreviewMetaTags adds the meta tags that apply the policy to the mail HTML when it is shown through srcdoc, where
+// the response headers are not seen. They go at the start of the head, or after the doctype when there is no head, so
+// that mail with a doctype is not shown in quirks mode.
+func addPreviewMetaTags(body string, policy []string) string {
+	tags := `<meta http-equiv="Content-Security-Policy" content="` + strings.Join(policy, "; ") + `">` +
+		`<meta name="referrer" content="no-referrer">`
+
+	at := 0
+
+	if location := headStartTag.FindStringIndex(body); location != nil {
+		at = location[1]
+	} else if location := doctype.FindStringIndex(body); location != nil {
+		at = location[1]
+	}
+
+	return body[:at] + tags + body[at:]
+}
+
+// setPreviewHeaders sets the headers for serving unsanitized mail HTML. The sandbox directive without any allowances
+// gives the document a unique origin, so it cannot reach the session of the UI or the API even though it is served from
+// the same host.
+func setPreviewHeaders(writer http.ResponseWriter, policy []string) {
+	policy = append([]string{"sandbox"}, policy...)
+	policy = append(policy, "frame-ancestors 'self'")
+
+	writer.Header().Set("Content-Security-Policy", strings.Join(policy, "; "))
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.Header().Set("Referrer-Policy", "no-referrer")
+}
+
+// attachmentDataURL returns the contents of an attachment as a data URL, for embedding inline parts in the preview.
+func attachmentDataURL(attachment model.Attachment) string {
+	contentType, _, err := mime.ParseMediaType(attachment.ContentType)
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+
+	contents := strings.Join(strings.Fields(attachment.Contents), "")
+	if !attachment.IsContentBase64() {
+		contents = base64.StdEncoding.EncodeToString([]byte(attachment.Contents))
+	}
+
+	return "data:" + contentType + ";base64," + contents
+}
@@ -0,0 +1,132 @@
+package handlers_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/middleware"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestPreviewMail(t *testing.T) {
+	t.Parallel()
+
+	imageID := uuid.Must(uuid.FromString("0b6f0a1e-4a44-4cf4-9a40-0f8ea1d0d8c5"))
+
+	mailItem := model.MailItem{
+		HTMLBody: `<img src="cid:logo@example.com"><img src="https://example.com/a.png"><script>alert(1)</script>`,
+		Attachments: []model.Attachment{
+			{
+				ID:          imageID,
+				ContentType: `image/png; name="logo.png"`,
+				ContentID:   "logo@example.com",
+				Inline:      true,
+				Contents:    "iVBORw0K\r\nGgoAAAA=",
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		code     int
+		imageSrc string
+	}{
+		{
+			name:     "remote images are blocked by default",
+			code:     http.StatusOK,
+			imageSrc: "img-src data:;",
+		},
+		{
+			name:     "remote images may be allowed",
+			query:    "?remoteImages=true",
+			code:     http.StatusOK,
+			imageSrc: "img-src data: http: https:;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			handler := handlers.PreviewMail(logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail/1234/preview"+test.query, nil)
+			request = request.WithContext(middleware.AttachMailItem(request.Context(), mailItem))
+
+			handler(recorder, request)
+
+			assert.Equal(t, test.code, recorder.Code, "response code should match expected")
+
+			policy := recorder.Header().Get("Content-Security-Policy")
+			assert.Contains(t, policy, "sandbox;")
+			assert.Contains(t, policy, "default-src 'none';")
+			assert.Contains(t, policy, test.imageSrc)
+			assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+
+			// the policy travels with the html, which is served as sent apart from inline parts being embedded
+			meta, body, found := strings.Cut(recorder.Body.String(), `<meta name="referrer" content="no-referrer">`)
+			assert.True(t, found, "referrer policy should be set in the document")
+			assert.Contains(t, meta, `<meta http-equiv="Content-Security-Policy" content="default-src 'none'; `+test.imageSrc)
+			assert.Equal(t, `<img src="data:image/png;base64,iVBORw0KGgoAAAA=">`+
+				`<img src="https://example.com/a.png"><script>alert(1)</script>`, body)
+		})
+	}
+}
+
+func TestPreviewMail_MetaTags(t *testing.T) {
+	t.Parallel()
+
+	const tags = `<meta http-equiv="Content-Security-Policy" content="default-src 'none'; img-src data:; ` +
+		`style-src 'unsafe-inline'; font-src data:; base-uri 'none'; form-action 'none'">` +
+		`<meta name="referrer" content="no-referrer">`
+
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "fragment",
+			html:     `<p>hello</p>`,
+			expected: tags + `<p>hello</p>`,
+		},
+		{
+			name:     "doctype stays first",
+			html:     "<!DOCTYPE html>\r\n<html><body><header>hi</header></body></html>",
+			expected: "<!DOCTYPE html>" + tags + "\r\n<html><body><header>hi</header></body></html>",
+		},
+		{
+			name:     "head start tag",
+			html:     `<!doctype html><html><HEAD lang="en"><title>hi</title></HEAD><body></body></html>`,
+			expected: `<!doctype html><html><HEAD lang="en">` + tags + `<title>hi</title></HEAD><body></body></html>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+			handler := handlers.PreviewMail(logger)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/mail/1234/preview", nil)
+			request = request.WithContext(middleware.AttachMailItem(request.Context(), model.MailItem{HTMLBody: test.html}))
+
+			handler(recorder, request)
+
+			assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+			assert.Equal(t, test.expected, recorder.Body.String())
+		})
+	}
+}
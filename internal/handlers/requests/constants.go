@@ -4,4 +4,8 @@ const (
 	PruneCodePathParam        = "pruneCode"
 	MailIDPathParam           = "mailId"
 	MailAttachmentIDPathParam = "attachmentId"
+	MailPartIDPathParam       = "partId"
+
+	// HeaderQueryParam may be given more than once to search mail by several headers.
+	HeaderQueryParam = "header"
 )
@@ -33,20 +33,29 @@ func APIQueryParams[T any](request *http.Request) (*T, error) {
 		if params.Has(split[0]) {
 			inp := params.Get(split[0])
 
-			if fieldType.Type.Kind() != reflect.Pointer {
-				return parsed, response.ErrNotAPointer
+			var value reflect.Value
+
+			// fields may be pointers, to tell a missing param apart from an empty one, or plain values
+			kind := fieldType.Type.Kind()
+			if kind == reflect.Pointer {
+				kind = fieldType.Type.Elem().Kind()
 			}
 
-			name := valueOf.Field(fieldIdx).Type().Elem().Name()
-			switch name {
-			case "string":
-				reflect.ValueOf(parsed).Elem().FieldByName(fieldType.Name).Set(reflect.ValueOf(&inp))
-			case "bool":
+			switch kind {
+			case reflect.String:
+				value = reflect.ValueOf(&inp)
+			case reflect.Bool:
 				boolVal := strings.ToLower(inp) == "true"
-				reflect.ValueOf(parsed).Elem().FieldByName(fieldType.Name).Set(reflect.ValueOf(&boolVal))
+				value = reflect.ValueOf(&boolVal)
 			default:
 				return nil, response.ErrUnexpectedDataType
 			}
+
+			if fieldType.Type.Kind() != reflect.Pointer {
+				value = value.Elem()
+			}
+
+			reflect.ValueOf(parsed).Elem().FieldByName(fieldType.Name).Set(value)
 		}
 	}
 
@@ -0,0 +1,20 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package response
+
+import (
+	"net/http"
+
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+)
+
+// FaultRulesResponse reports the fault injection rules in effect on the SMTP server.
+type FaultRulesResponse struct {
+	slurperio.FaultConfig
+}
+
+// Render implements the render.Renderer interface for use with chi-router.
+func (_ *FaultRulesResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
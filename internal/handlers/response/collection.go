@@ -15,6 +15,9 @@ type MailCollectionResponse struct {
 	MailItems    []model.MailItem `json:"mailItems"`
 	TotalPages   int              `json:"totalPages"`
 	TotalRecords int              `json:"totalRecords"`
+
+	// NextCursor continues a search by cursor or since. It is left out when there is nothing further to page to.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 // Render implements the render.Renderer interface for use with chi-router.
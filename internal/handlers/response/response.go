@@ -3,6 +3,7 @@ package response
 import (
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"reflect"
 
@@ -263,11 +264,14 @@ func (_ *HTMLResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
 	return nil
 }
 
-// DataResponse represents an octet stream response.
+// DataResponse represents a binary response. ContentType defaults to application/octet-stream and a non-empty
+// FileName asks the client to download the data as an attachment.
 type DataResponse struct {
 	HTTPStatusCode int
 	StatusText     string
 	Data           []byte
+	ContentType    string
+	FileName       string
 }
 
 // Render implements the render.Renderer interface for use with chi-router.
@@ -279,7 +283,11 @@ func (_ *DataResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
 func SetDefaultResponder() func(http.ResponseWriter, *http.Request, any) {
 	return func(writer http.ResponseWriter, request *http.Request, value any) {
 		switch valueType := value.(type) {
-		case *APIResponse, *APIListResponse:
+		case *APIResponse:
+			setStatus(request, valueType.HTTPStatusCode)
+			render.JSON(writer, request, valueType)
+		case *APIListResponse:
+			setStatus(request, valueType.HTTPStatusCode)
 			render.JSON(writer, request, valueType)
 		case *ImageResponse:
 			var mime string
@@ -295,19 +303,55 @@ func SetDefaultResponder() func(http.ResponseWriter, *http.Request, any) {
 			writer.WriteHeader(valueType.HTTPStatusCode)
 			_, _ = writer.Write(valueType.Data)
 		case *TextResponse:
+			setStatus(request, valueType.HTTPStatusCode)
 			render.PlainText(writer, request, string(valueType.Data))
 		case *JSONResponse:
+			setStatus(request, valueType.HTTPStatusCode)
 			render.JSON(writer, request, valueType.Value)
 		case *HTMLResponse:
+			setStatus(request, valueType.HTTPStatusCode)
 			render.HTML(writer, request, valueType.Value)
 		case *DataResponse:
-			render.Data(writer, request, valueType.Data)
+			writeData(writer, valueType)
 		default:
 			panic("response body incorrectly formatted")
 		}
 	}
 }
 
+// writeData writes a binary response. render.Data always sets an octet stream content type, so the headers are written
+// here instead.
+func writeData(writer http.ResponseWriter, value *DataResponse) {
+	contentType := value.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if value.FileName != "" {
+		writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": value.FileName,
+		}))
+	}
+
+	status := value.HTTPStatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	writer.WriteHeader(status)
+	_, _ = writer.Write(value.Data)
+}
+
+// setStatus passes a response status on to the renderer. An unset status is left to default to 200.
+func setStatus(request *http.Request, status int) {
+	if status != 0 {
+		render.Status(request, status)
+	}
+}
+
 // SetDefaultDecoder ...
 func SetDefaultDecoder() func(*http.Request, interface{}) error {
 	return func(request *http.Request, value interface{}) error {
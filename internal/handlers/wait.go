@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/requests"
+	"github.com/mailslurper/mailslurper/v2/internal/handlers/response"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+var ErrWaitTimeout = errors.New("no matching mail received")
+
+// MailSubscriber hands out the mail received by the SMTP server as it is stored.
+type MailSubscriber interface {
+	Subscribe() (<-chan *model.MailItem, func())
+}
+
+type MailWaitGetter interface {
+	GetMailCollection(int, int, *persistence.MailSearch) ([]model.MailItem, error)
+	GetMailByID(uuid.UUID) (*model.MailItem, error)
+}
+
+type WaitForMailParams struct {
+	Message string `form:"message,omitempty" json:"message,omitempty"`
+	Subject string `form:"subject,omitempty" json:"subject,omitempty"`
+	From    string `form:"from,omitempty" json:"from,omitempty"`
+	To      string `form:"to,omitempty" json:"to,omitempty"`
+	Since   string `form:"since,omitempty" json:"since,omitempty"`
+	Timeout string `form:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// WaitForMail blocks until a mail matching the search is received and returns it. The search takes the same criteria
+// as the mail listing. Only mail received after the request arrives is matched, unless a since cursor is given, in
+// which case mail already stored after the cursor is returned straight away. This lets a test take a cursor before it
+// triggers a mail, so the mail cannot slip in before the wait starts.
+//
+// The request gives up with 408 Request Timeout after the timeout, which defaults to 30s and may be up to 5m.
+//
+// GET: /mail/wait?to={to}&subject={subject}&since={cursor}&timeout={timeout}
+func WaitForMail(
+	data MailWaitGetter,
+	subscriber MailSubscriber,
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		params, err := requests.APIQueryParams[WaitForMailParams](request)
+		if err != nil {
+			response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+			return
+		}
+
+		timeout := defaultWaitTimeout
+		if params.Timeout != "" {
+			if timeout, err = time.ParseDuration(params.Timeout); err != nil || timeout <= 0 || timeout > maxWaitTimeout {
+				err = fmt.Errorf("%w: timeout must be a duration up to %s - %s", response.ErrInvalidInput, maxWaitTimeout, params.Timeout)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+		}
+
+		message, headers := persistence.ParseMessageSearch(params.Message)
+
+		for _, term := range request.URL.Query()[requests.HeaderQueryParam] {
+			if header, ok := persistence.ParseHeaderSearch(term); ok {
+				headers = append(headers, header)
+			}
+		}
+
+		mailSearch := &persistence.MailSearch{
+			Message: message,
+			Subject: params.Subject,
+			From:    params.From,
+			To:      params.To,
+			Headers: headers,
+		}
+
+		// subscribe before looking at stored mail, so that nothing arriving in between is missed
+		mailItems, unsubscribe := subscriber.Subscribe()
+		defer unsubscribe()
+
+		if params.Since != "" {
+			if mailSearch.Since, err = persistence.ParseMailCursor(params.Since); err != nil {
+				err = fmt.Errorf("%w: since cursor passed to WaitForMail - %s", err, params.Since)
+
+				response.RenderOrLog(writer, request, response.HTTPBadRequest(err), logger)
+
+				return
+			}
+
+			stored, err := data.GetMailCollection(0, 1, mailSearch)
+			if err != nil {
+				err = fmt.Errorf("%w: problem getting mail collection", err)
+
+				response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+				return
+			}
+
+			if len(stored) > 0 {
+				renderWaitedMail(writer, request, data, stored[0].ID, logger)
+
+				return
+			}
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case mailItem := <-mailItems:
+				if mailSearch.Matches(mailItem) {
+					renderWaitedMail(writer, request, data, mailItem.ID, logger)
+
+					return
+				}
+			case <-timer.C:
+				err = fmt.Errorf("%w within %s", ErrWaitTimeout, timeout)
+
+				response.RenderOrLog(writer, request, response.HTTPStatusError(http.StatusRequestTimeout, err), logger)
+
+				return
+			case <-request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// WaitForMailUnavailable answers requests to wait for mail with 404 when the SMTP server does not run in the same
+// process, as there is no mail arriving to wait for.
+//
+// GET: /mail/wait
+func WaitForMailUnavailable(
+	logger *log.Logger,
+) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		err := fmt.Errorf("%w: waiting for mail needs the SMTP server in the same process", response.ErrNotFound)
+
+		response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+	}
+}
+
+// renderWaitedMail loads a mail item that was waited for and renders it as it would be by GetMail.
+func renderWaitedMail(
+	writer http.ResponseWriter,
+	request *http.Request,
+	data MailWaitGetter,
+	mailID uuid.UUID,
+	logger *log.Logger,
+) {
+	mailItem, err := data.GetMailByID(mailID)
+	if err != nil {
+		err = fmt.Errorf("%w: Problem getting mail item %s", err, mailID)
+
+		response.RenderOrLog(writer, request, response.HTTPInternalServerError(err), logger)
+
+		return
+	}
+
+	if mailItem == nil {
+		err = fmt.Errorf("%w: mail item %s", response.ErrNotFound, mailID)
+
+		response.RenderOrLog(writer, request, response.HTTPNotFound(err), logger)
+
+		return
+	}
+
+	logger.Printf("Mail item %s received while waiting", mailItem.ID)
+	response.RenderOrLog(writer, request, &response.JSONResponse{
+		HTTPStatusCode: http.StatusOK,
+		Value:          mailItem,
+	}, logger)
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailslurper/mailslurper/v2/internal/handlers"
+	"github.com/mailslurper/mailslurper/v2/internal/mocks"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+func TestWaitForMail(t *testing.T) {
+	t.Parallel()
+
+	other := &model.MailItem{
+		ID:          uuid.Must(uuid.NewV4()),
+		ToAddresses: model.MailAddressCollection{"bob@x.test"},
+		Subject:     "Reset",
+	}
+	expected := &model.MailItem{
+		ID:          uuid.Must(uuid.NewV4()),
+		ToAddresses: model.MailAddressCollection{"alice@x.test"},
+		Subject:     "Reset your password",
+	}
+
+	t.Run("matching mail arrives", func(t *testing.T) {
+		t.Parallel()
+
+		logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+		mData := new(mocks.MockPersistance)
+		subscriber := newTestSubscriber()
+
+		mData.EXPECT().GetMailByID(expected.ID).Return(expected, nil)
+
+		handler := handlers.WaitForMail(mData, subscriber, logger)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/mail/wait?to=alice@x.test&subject=Reset&timeout=5s", nil)
+
+		go func() {
+			<-subscriber.subscribed
+			subscriber.mail <- other
+			subscriber.mail <- expected
+		}()
+
+		handler(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+		assert.Contains(t, recorder.Body.String(), expected.ID.String())
+
+		mData.AssertExpectations(t)
+	})
+
+	t.Run("stored since cursor", func(t *testing.T) {
+		t.Parallel()
+
+		logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+		mData := new(mocks.MockPersistance)
+
+		since := persistence.NewMailCursor(&model.MailItem{ID: other.ID, CreatedAt: time.Now()}).String()
+
+		mData.EXPECT().GetMailCollection(0, 1, mock.Anything).Return([]model.MailItem{*expected}, nil)
+		mData.EXPECT().GetMailByID(expected.ID).Return(expected, nil)
+
+		handler := handlers.WaitForMail(mData, newTestSubscriber(), logger)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/mail/wait?to=alice@x.test&since="+since, nil)
+
+		handler(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code, "response code should match expected")
+		assert.Contains(t, recorder.Body.String(), expected.ID.String())
+
+		mData.AssertExpectations(t)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+		mData := new(mocks.MockPersistance)
+
+		handler := handlers.WaitForMail(mData, newTestSubscriber(), logger)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/mail/wait?to=alice@x.test&timeout=10ms", nil)
+
+		handler(recorder, request)
+
+		assert.Equal(t, http.StatusRequestTimeout, recorder.Code, "response code should match expected")
+
+		mData.AssertExpectations(t)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		t.Parallel()
+
+		logger := slog.NewLogLogger(slog.DiscardHandler, slog.LevelDebug)
+		handler := handlers.WaitForMail(new(mocks.MockPersistance), newTestSubscriber(), logger)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/mail/wait?timeout=1h", nil)
+
+		handler(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "response code should match expected")
+	})
+}
+
+type testSubscriber struct {
+	mail       chan *model.MailItem
+	subscribed chan struct{}
+}
+
+func newTestSubscriber() *testSubscriber {
+	return &testSubscriber{
+		mail:       make(chan *model.MailItem),
+		subscribed: make(chan struct{}, 1),
+	}
+}
+
+func (s *testSubscriber) Subscribe() (<-chan *model.MailItem, func()) {
+	s.subscribed <- struct{}{}
+
+	return s.mail, func() {}
+}
@@ -9,25 +9,43 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/mailslurper/mailslurper/v2/internal/persistence"
 	"github.com/mailslurper/mailslurper/v2/pkg/auth/authscheme"
 )
 
 var (
-	ErrInvalidAdminAddress    = errors.New("Invalid administrator address: admin.address")
-	ErrInvalidPublicAddress   = errors.New("Invalid service address: public.address")
-	ErrInvalidSMTPAddress     = errors.New("Invalid SMTP address: smtp.address")
-	ErrInvalidDatabaseDialect = errors.New("Invalid database dialiect. Valid values are 'sqlite', 'mysql', 'mssql', `postgres`")
-	ErrInvalidDatabaseHost    = errors.New("Invalid database host: database.host")
-	ErrInvalidDatabaseName    = errors.New("Invalid database name: database.name")
-	ErrKeyFileNotFound        = errors.New("Key file not found")
-	ErrCertFileNotFound       = errors.New("Certificate file not found")
-	ErrNeedCertPair           = errors.New("Please provide both a key file and a cert file")
-	ErrInvalidAuthScheme      = errors.New("Invalid authentication scheme. Valid values are 'basic': authenticationScheme")
-	ErrMissingAuthSecret      = errors.New("Missing authentication secret. An authentication secret is requried when authentication is enabled: authSecret")
-	ErrMissingAuthSalt        = errors.New("Missing authentication salt. A salt value is required when authentication is enabled: authSalt")
-	ErrNoUsersConfigured      = errors.New("No users configured. When authentication is enabled you must have at least 1 valid user: credentials")
+	ErrInvalidAdminAddress      = errors.New("Invalid administrator address: admin.address")
+	ErrInvalidPublicAddress     = errors.New("Invalid service address: public.address")
+	ErrInvalidSMTPAddress       = errors.New("Invalid SMTP address: smtp.address")
+	ErrInvalidDatabaseDialect   = errors.New("Invalid database dialiect. Valid values are 'sqlite', 'mysql', 'mssql', `postgres`")
+	ErrInvalidDatabaseHost      = errors.New("Invalid database host: database.host")
+	ErrInvalidDatabaseName      = errors.New("Invalid database name: database.name")
+	ErrKeyFileNotFound          = errors.New("Key file not found")
+	ErrCertFileNotFound         = errors.New("Certificate file not found")
+	ErrNeedCertPair             = errors.New("Please provide both a key file and a cert file")
+	ErrInvalidAuthScheme        = errors.New("Invalid authentication scheme. Valid values are 'basic': authenticationScheme")
+	ErrMissingAuthSecret        = errors.New("Missing authentication secret. An authentication secret is requried when authentication is enabled: authSecret")
+	ErrMissingAuthSalt          = errors.New("Missing authentication salt. A salt value is required when authentication is enabled: authSalt")
+	ErrNoUsersConfigured        = errors.New("No users configured. When authentication is enabled you must have at least 1 valid user: credentials")
+	ErrInvalidStartTLSMode      = errors.New("Invalid STARTTLS mode. Valid values are 'optional', 'required': smtp.startTLS")
+	ErrStartTLSNeedsCertPair    = errors.New("STARTTLS requires both a key file and a cert file: smtp.keyFile, smtp.certificateFile")
+	ErrInvalidSMTPAuthMode      = errors.New("Invalid SMTP authentication mode. Valid values are 'any', 'credentials': smtp.auth.mode")
+	ErrNoSMTPUsersConfigured    = errors.New("No SMTP users configured. When SMTP credentials are checked you must have at least 1 user: smtp.auth.credentials")
+	ErrInvalidMaxMessageSize    = errors.New("Invalid maximum message size. The value must be 0 (no limit) or a positive number of bytes: smtp.maxMessageSize")
+	ErrInvalidFaultLatency      = errors.New("Invalid fault latency. The value must be a non-negative duration such as '250ms': smtp.faults.latency")
+	ErrInvalidFaultPercent      = errors.New("Invalid fault percentage. The value must be between 0 and 100: smtp.faults")
+	ErrInvalidFaultCode         = errors.New("Invalid fault reply code. The value must be a 4xx or 5xx SMTP reply code: smtp.faults")
+	ErrInvalidFaultPattern      = errors.New("Invalid fault recipient pattern: smtp.faults.recipients")
+	ErrInvalidGreylistDelay     = errors.New("Invalid greylisting delay. The value must be a non-negative duration such as '5m': smtp.greylist.delay")
+	ErrInvalidGreylistExpiry    = errors.New("Invalid greylisting expiry. The value must be a positive duration such as '24h': smtp.greylist.expiry")
+	ErrInvalidPageSize          = errors.New("Invalid page size. The value must be between 1 and 500, or 0 for the default of 50: pageSize")
+	ErrInvalidWebhookURL        = errors.New("Invalid webhook URL. The value must be an absolute http or https URL: webhooks.url")
+	ErrInvalidWebhookPattern    = errors.New("Invalid webhook address pattern: webhooks.recipients, webhooks.senders")
+	ErrInvalidWebhookAttempts   = errors.New("Invalid webhook attempts. The value must be a positive number, or 0 for the default of 5: webhooks.maxAttempts")
+	ErrInvalidWebhookRetryDelay = errors.New("Invalid webhook retry delay. The value must be a non-negative duration such as '1s': webhooks.retryDelay")
+	ErrInvalidWebhookTimeout    = errors.New("Invalid webhook timeout. The value must be a positive duration such as '10s': webhooks.timeout")
 
 	defaultNixConfigPath     = filepath.Base("~/.config/mailslurper")
 	defaultWindowsConfigPath = filepath.Base(`%appdata%\mailslurper`)
@@ -37,10 +55,14 @@ var (
 // Config contains settings for how to bind servers and connect to databases.
 type Config struct {
 	Public     ListenConfig       `mapstructure:"public"`
-	SMTP       ListenConfig       `mapstructure:"smtp"`
+	SMTP       SMTPConfig         `mapstructure:"smtp"`
 	Database   persistence.Config `mapstructure:"database"`
 	MaxWorkers int                `mapstructure:"maxWorkers"`
 	Theme      string             `mapstructure:"theme"`
+	// PageSize is the number of mail items in a page of the mail listing when a request does not ask for a size.
+	PageSize int `mapstructure:"pageSize"`
+	// Webhooks are sent a summary of each mail received.
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
 
 	AuthSecret           string            `mapstructure:"authSecret"`
 	AuthSalt             string            `mapstructure:"authSalt"`
@@ -60,6 +82,21 @@ func (c *Config) GetTheme() string {
 	return c.Theme
 }
 
+// Mail listing page sizes.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// GetPageSize returns the configured page size, or the default if none is set.
+func (c *Config) GetPageSize() int {
+	if c.PageSize == 0 {
+		return DefaultPageSize
+	}
+
+	return c.PageSize
+}
+
 type ListenConfig struct {
 	Address   string `mapstructure:"address"`
 	Port      int    `mapstructure:"port"`
@@ -117,6 +154,169 @@ func (c ListenConfig) isValidFile(filename string) bool {
 	return true
 }
 
+// StartTLSMode determines whether the SMTP listener offers the STARTTLS extension on plain connections.
+type StartTLSMode string
+
+const (
+	StartTLSDisabled StartTLSMode = ""
+	StartTLSOptional StartTLSMode = "optional"
+	StartTLSRequired StartTLSMode = "required"
+)
+
+// IsEnabled returns true if STARTTLS should be advertised to clients.
+func (m StartTLSMode) IsEnabled() bool {
+	return m == StartTLSOptional || m == StartTLSRequired
+}
+
+// IsValid returns true if the mode is one of the known STARTTLS modes.
+func (m StartTLSMode) IsValid() bool {
+	return m == StartTLSDisabled || m.IsEnabled()
+}
+
+// SMTPAuthMode determines how the SMTP server handles the AUTH command.
+type SMTPAuthMode string
+
+const (
+	SMTPAuthDisabled    SMTPAuthMode = ""
+	SMTPAuthAny         SMTPAuthMode = "any"
+	SMTPAuthCredentials SMTPAuthMode = "credentials"
+)
+
+// IsEnabled returns true if the AUTH extension should be advertised to clients.
+func (m SMTPAuthMode) IsEnabled() bool {
+	return m == SMTPAuthAny || m == SMTPAuthCredentials
+}
+
+// IsValid returns true if the mode is one of the known authentication modes.
+func (m SMTPAuthMode) IsValid() bool {
+	return m == SMTPAuthDisabled || m.IsEnabled()
+}
+
+// SMTPAuthConfig contains settings for the SMTP AUTH extension.
+type SMTPAuthConfig struct {
+	// Mode is 'any' to accept every set of credentials, or 'credentials' to check them against Credentials.
+	Mode SMTPAuthMode `mapstructure:"mode"`
+	// Credentials maps user names to passwords. Unlike the web credentials these are stored in plain text, as
+	// CRAM-MD5 requires the shared secret to verify a client response.
+	Credentials map[string]string `mapstructure:"credentials"`
+}
+
+func (c SMTPAuthConfig) Validate() error {
+	if !c.Mode.IsValid() {
+		return ErrInvalidSMTPAuthMode
+	}
+
+	if c.Mode == SMTPAuthCredentials && len(c.Credentials) < 1 {
+		return ErrNoSMTPUsersConfigured
+	}
+
+	return nil
+}
+
+// Default greylisting durations.
+const (
+	DefaultGreylistDelay  = time.Minute
+	DefaultGreylistExpiry = 24 * time.Hour
+)
+
+// GreylistConfig contains settings for simulated greylisting.
+type GreylistConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Delay is how long a client must wait before retrying a refused delivery, such as "30s". It defaults to 1 minute.
+	Delay string `mapstructure:"delay"`
+	// Expiry is how long a client, sender and recipient triplet is remembered after it was last seen. It defaults to
+	// 24 hours.
+	Expiry string `mapstructure:"expiry"`
+}
+
+// GetDelay returns the parsed delay, or the default if none is set.
+func (c GreylistConfig) GetDelay() time.Duration {
+	return parseDurationOr(c.Delay, DefaultGreylistDelay)
+}
+
+// GetExpiry returns the parsed expiry, or the default if none is set.
+func (c GreylistConfig) GetExpiry() time.Duration {
+	return parseDurationOr(c.Expiry, DefaultGreylistExpiry)
+}
+
+func (c GreylistConfig) Validate() error {
+	if c.Delay != "" {
+		if delay, err := time.ParseDuration(c.Delay); err != nil || delay < 0 {
+			return ErrInvalidGreylistDelay
+		}
+	}
+
+	if c.Expiry != "" {
+		if expiry, err := time.ParseDuration(c.Expiry); err != nil || expiry <= 0 {
+			return ErrInvalidGreylistExpiry
+		}
+	}
+
+	return nil
+}
+
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+
+	return duration
+}
+
+// SMTPConfig contains the listener settings for the SMTP server along with the protocol options offered to clients.
+type SMTPConfig struct {
+	ListenConfig `mapstructure:",squash"`
+
+	// StartTLS enables the STARTTLS extension on a plain listener using the configured certificate pair. When set to
+	// 'required' clients must upgrade the connection before issuing MAIL FROM.
+	StartTLS StartTLSMode `mapstructure:"startTLS"`
+	// Auth enables the AUTH extension with the PLAIN, LOGIN and CRAM-MD5 mechanisms.
+	Auth SMTPAuthConfig `mapstructure:"auth"`
+	// MaxMessageSize is the largest message in bytes accepted with DATA. It is advertised with the SIZE extension.
+	// Zero means there is no limit.
+	MaxMessageSize int64 `mapstructure:"maxMessageSize"`
+	// Faults makes the server fail on purpose, to exercise the retry and bounce handling of clients. The rules can be
+	// changed at runtime through the admin API.
+	Faults FaultConfig `mapstructure:"faults"`
+	// Greylist refuses the first delivery attempt for each client, sender and recipient with a temporary failure.
+	Greylist GreylistConfig `mapstructure:"greylist"`
+}
+
+func (c SMTPConfig) Validate() error {
+	if err := c.ListenConfig.Validate(); err != nil {
+		return err
+	}
+
+	if !c.StartTLS.IsValid() {
+		return ErrInvalidStartTLSMode
+	}
+
+	if c.StartTLS.IsEnabled() && !c.ListenConfig.IsSSL() {
+		return ErrStartTLSNeedsCertPair
+	}
+
+	if c.MaxMessageSize < 0 {
+		return ErrInvalidMaxMessageSize
+	}
+
+	if err := c.Faults.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Greylist.Validate(); err != nil {
+		return err
+	}
+
+	return c.Auth.Validate()
+}
+
+// IsSSL returns true if the SMTP listener should use implicit TLS. A certificate pair used for STARTTLS does not make
+// the listener implicit TLS.
+func (c SMTPConfig) IsSSL() bool {
+	return c.ListenConfig.IsSSL() && !c.StartTLS.IsEnabled()
+}
+
 func (config Config) Validate() error {
 	if config.Public.Address == "" {
 		return ErrInvalidPublicAddress
@@ -130,6 +330,20 @@ func (config Config) Validate() error {
 		return err
 	}
 
+	if err := config.SMTP.Validate(); err != nil {
+		return err
+	}
+
+	if config.PageSize < 0 || config.PageSize > MaxPageSize {
+		return ErrInvalidPageSize
+	}
+
+	for _, webhook := range config.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if config.AuthenticationScheme != "" {
 		if !authscheme.IsValidAuthScheme(config.AuthenticationScheme) {
 			return ErrInvalidAuthScheme
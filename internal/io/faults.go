@@ -0,0 +1,116 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package io
+
+import (
+	"path"
+	"time"
+)
+
+// FaultConfig describes failures injected by the SMTP server. All faults are off by default.
+type FaultConfig struct {
+	// Latency is a delay such as "250ms" added before every reply to a command.
+	Latency string `mapstructure:"latency" json:"latency,omitempty"`
+	// Rcpt fails a share of RCPT TO commands.
+	Rcpt FaultRate `mapstructure:"rcpt" json:"rcpt"`
+	// Data fails a share of messages once they have been received with DATA or BDAT.
+	Data FaultRate `mapstructure:"data" json:"data"`
+	// DropPercent is the share of DATA commands, from 0 to 100, during which the connection is closed before the
+	// message has been received.
+	DropPercent float64 `mapstructure:"dropPercent" json:"dropPercent"`
+	// Recipients rejects recipients matching a pattern on every attempt.
+	Recipients []RecipientFault `mapstructure:"recipients" json:"recipients"`
+}
+
+// FaultRate fails a percentage of commands with a reply code.
+type FaultRate struct {
+	// Percent is the share of commands to fail, from 0 to 100.
+	Percent float64 `mapstructure:"percent" json:"percent"`
+	// Code is the 4xx or 5xx reply code to send. It defaults to 451.
+	Code int `mapstructure:"code" json:"code,omitempty"`
+	// Message replaces the default reply text.
+	Message string `mapstructure:"message" json:"message,omitempty"`
+}
+
+// RecipientFault rejects recipients matching a pattern.
+type RecipientFault struct {
+	// Pattern is matched against the whole recipient address, ignoring case. "*" matches any run of characters, so
+	// "*@bounce.test" rejects every recipient at bounce.test.
+	Pattern string `mapstructure:"pattern" json:"pattern"`
+	// Code is the 4xx or 5xx reply code to send. It defaults to 550.
+	Code int `mapstructure:"code" json:"code,omitempty"`
+	// Message replaces the default reply text.
+	Message string `mapstructure:"message" json:"message,omitempty"`
+}
+
+// GetLatency returns the parsed latency, or zero if there is none.
+func (c FaultConfig) GetLatency() time.Duration {
+	latency, _ := time.ParseDuration(c.Latency)
+
+	return latency
+}
+
+func (c FaultConfig) Validate() error {
+	if c.Latency != "" {
+		if latency, err := time.ParseDuration(c.Latency); err != nil || latency < 0 {
+			return ErrInvalidFaultLatency
+		}
+	}
+
+	if err := c.Rcpt.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Data.Validate(); err != nil {
+		return err
+	}
+
+	if !isValidPercent(c.DropPercent) {
+		return ErrInvalidFaultPercent
+	}
+
+	for _, recipient := range c.Recipients {
+		if err := recipient.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r FaultRate) Validate() error {
+	if !isValidPercent(r.Percent) {
+		return ErrInvalidFaultPercent
+	}
+
+	if !isValidFaultCode(r.Code) {
+		return ErrInvalidFaultCode
+	}
+
+	return nil
+}
+
+func (r RecipientFault) Validate() error {
+	if r.Pattern == "" {
+		return ErrInvalidFaultPattern
+	}
+
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return ErrInvalidFaultPattern
+	}
+
+	if !isValidFaultCode(r.Code) {
+		return ErrInvalidFaultCode
+	}
+
+	return nil
+}
+
+func isValidPercent(percent float64) bool {
+	return percent >= 0 && percent <= 100
+}
+
+func isValidFaultCode(code int) bool {
+	return code == 0 || (code >= 400 && code <= 599)
+}
@@ -0,0 +1,88 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package io
+
+import (
+	"net/url"
+	"path"
+	"time"
+)
+
+// Default webhook delivery settings.
+const (
+	DefaultWebhookMaxAttempts = 5
+	DefaultWebhookRetryDelay  = time.Second
+	DefaultWebhookTimeout     = 10 * time.Second
+)
+
+// WebhookConfig describes a URL that is sent a JSON summary of each mail received.
+type WebhookConfig struct {
+	// URL is the http or https address the summary is posted to.
+	URL string `mapstructure:"url"`
+	// Secret signs each request with an HMAC-SHA256 of the body, sent in the X-MailSlurper-Signature header. Requests
+	// are not signed without a secret.
+	Secret string `mapstructure:"secret"`
+	// Recipients only sends mail with an envelope recipient matching one of the patterns. Patterns are matched against
+	// the whole address, ignoring case, and "*" matches any run of characters.
+	Recipients []string `mapstructure:"recipients"`
+	// Senders only sends mail with an envelope sender matching one of the patterns, in the same way as Recipients.
+	Senders []string `mapstructure:"senders"`
+	// MaxAttempts is how many times delivery is tried before the mail is given up on. It defaults to 5.
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// RetryDelay is how long to wait before the first retry, such as "500ms". The wait doubles with each retry. It
+	// defaults to 1 second.
+	RetryDelay string `mapstructure:"retryDelay"`
+	// Timeout is how long a single attempt may take, such as "5s". It defaults to 10 seconds.
+	Timeout string `mapstructure:"timeout"`
+}
+
+// GetMaxAttempts returns the configured number of attempts, or the default if none is set.
+func (c WebhookConfig) GetMaxAttempts() int {
+	if c.MaxAttempts == 0 {
+		return DefaultWebhookMaxAttempts
+	}
+
+	return c.MaxAttempts
+}
+
+// GetRetryDelay returns the parsed retry delay, or the default if none is set.
+func (c WebhookConfig) GetRetryDelay() time.Duration {
+	return parseDurationOr(c.RetryDelay, DefaultWebhookRetryDelay)
+}
+
+// GetTimeout returns the parsed timeout, or the default if none is set.
+func (c WebhookConfig) GetTimeout() time.Duration {
+	return parseDurationOr(c.Timeout, DefaultWebhookTimeout)
+}
+
+func (c WebhookConfig) Validate() error {
+	target, err := url.Parse(c.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	for _, pattern := range append(append([]string{}, c.Recipients...), c.Senders...) {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return ErrInvalidWebhookPattern
+		}
+	}
+
+	if c.MaxAttempts < 0 {
+		return ErrInvalidWebhookAttempts
+	}
+
+	if c.RetryDelay != "" {
+		if delay, err := time.ParseDuration(c.RetryDelay); err != nil || delay < 0 {
+			return ErrInvalidWebhookRetryDelay
+		}
+	}
+
+	if c.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return ErrInvalidWebhookTimeout
+		}
+	}
+
+	return nil
+}
@@ -43,7 +43,7 @@ func ParseDateTime(dateString string, logger *slog.Logger) string {
 	 * If there is not date parsed, default to now
 	 */
 	if result == "" {
-		logger.Error("Problem parsing date %s", dateString)
+		logger.Error("Problem parsing date", "date", dateString)
 		result = time.Now().Format(outputFormat)
 	}
 
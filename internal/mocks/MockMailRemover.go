@@ -2,7 +2,10 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	uuid "github.com/gofrs/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
 
 // MockMailRemover is an autogenerated mock type for the MailRemover type
 type MockMailRemover struct {
@@ -73,6 +76,52 @@ func (_c *MockMailRemover_DeleteMailsAfterDate_Call) RunAndReturn(run func(strin
 	return _c
 }
 
+// DeleteMailByID provides a mock function with given fields: _a0
+func (_m *MockMailRemover) DeleteMailByID(_a0 uuid.UUID) error {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMailByID")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMailRemover_DeleteMailByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteMailByID'
+type MockMailRemover_DeleteMailByID_Call struct {
+	*mock.Call
+}
+
+// DeleteMailByID is a helper method to define mock.On call
+//   - _a0 uuid.UUID
+func (_e *MockMailRemover_Expecter) DeleteMailByID(_a0 interface{}) *MockMailRemover_DeleteMailByID_Call {
+	return &MockMailRemover_DeleteMailByID_Call{Call: _e.mock.On("DeleteMailByID", _a0)}
+}
+
+func (_c *MockMailRemover_DeleteMailByID_Call) Run(run func(_a0 uuid.UUID)) *MockMailRemover_DeleteMailByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockMailRemover_DeleteMailByID_Call) Return(_a0 error) *MockMailRemover_DeleteMailByID_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMailRemover_DeleteMailByID_Call) RunAndReturn(run func(uuid.UUID) error) *MockMailRemover_DeleteMailByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockMailRemover creates a new instance of MockMailRemover. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockMailRemover(t interface {
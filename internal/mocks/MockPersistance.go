@@ -254,22 +254,24 @@ func (_c *MockPersistance_GetMailCount_Call) RunAndReturn(run func(*persistence.
 }
 
 // GetMailMessageRawByID provides a mock function with given fields: _a0
-func (_m *MockPersistance) GetMailMessageRawByID(_a0 uuid.UUID) (string, error) {
+func (_m *MockPersistance) GetMailMessageRawByID(_a0 uuid.UUID) ([]byte, error) {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetMailMessageRawByID")
 	}
 
-	var r0 string
+	var r0 []byte
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]byte, error)); ok {
 		return rf(_a0)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID) string); ok {
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []byte); ok {
 		r0 = rf(_a0)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
@@ -299,12 +301,234 @@ func (_c *MockPersistance_GetMailMessageRawByID_Call) Run(run func(_a0 uuid.UUID
 	return _c
 }
 
-func (_c *MockPersistance_GetMailMessageRawByID_Call) Return(_a0 string, _a1 error) *MockPersistance_GetMailMessageRawByID_Call {
+func (_c *MockPersistance_GetMailMessageRawByID_Call) Return(_a0 []byte, _a1 error) *MockPersistance_GetMailMessageRawByID_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockPersistance_GetMailMessageRawByID_Call) RunAndReturn(run func(uuid.UUID) (string, error)) *MockPersistance_GetMailMessageRawByID_Call {
+func (_c *MockPersistance_GetMailMessageRawByID_Call) RunAndReturn(run func(uuid.UUID) ([]byte, error)) *MockPersistance_GetMailMessageRawByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetMessagePart provides a mock function with given fields: _a0, _a1
+func (_m *MockPersistance) GetMessagePart(_a0 uuid.UUID, _a1 uuid.UUID) (*model.MessagePart, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMessagePart")
+	}
+
+	var r0 *model.MessagePart
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (*model.MessagePart, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) *model.MessagePart); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MessagePart)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPersistance_GetMessagePart_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMessagePart'
+type MockPersistance_GetMessagePart_Call struct {
+	*mock.Call
+}
+
+// GetMessagePart is a helper method to define mock.On call
+//   - _a0 uuid.UUID
+//   - _a1 uuid.UUID
+func (_e *MockPersistance_Expecter) GetMessagePart(_a0 interface{}, _a1 interface{}) *MockPersistance_GetMessagePart_Call {
+	return &MockPersistance_GetMessagePart_Call{Call: _e.mock.On("GetMessagePart", _a0, _a1)}
+}
+
+func (_c *MockPersistance_GetMessagePart_Call) Run(run func(_a0 uuid.UUID, _a1 uuid.UUID)) *MockPersistance_GetMessagePart_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPersistance_GetMessagePart_Call) Return(_a0 *model.MessagePart, _a1 error) *MockPersistance_GetMessagePart_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPersistance_GetMessagePart_Call) RunAndReturn(run func(uuid.UUID, uuid.UUID) (*model.MessagePart, error)) *MockPersistance_GetMessagePart_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetMessagePartTree provides a mock function with given fields: _a0
+func (_m *MockPersistance) GetMessagePartTree(_a0 uuid.UUID) (*model.MessagePart, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMessagePartTree")
+	}
+
+	var r0 *model.MessagePart
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) (*model.MessagePart, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) *model.MessagePart); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MessagePart)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPersistance_GetMessagePartTree_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMessagePartTree'
+type MockPersistance_GetMessagePartTree_Call struct {
+	*mock.Call
+}
+
+// GetMessagePartTree is a helper method to define mock.On call
+//   - _a0 uuid.UUID
+func (_e *MockPersistance_Expecter) GetMessagePartTree(_a0 interface{}) *MockPersistance_GetMessagePartTree_Call {
+	return &MockPersistance_GetMessagePartTree_Call{Call: _e.mock.On("GetMessagePartTree", _a0)}
+}
+
+func (_c *MockPersistance_GetMessagePartTree_Call) Run(run func(_a0 uuid.UUID)) *MockPersistance_GetMessagePartTree_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPersistance_GetMessagePartTree_Call) Return(_a0 *model.MessagePart, _a1 error) *MockPersistance_GetMessagePartTree_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPersistance_GetMessagePartTree_Call) RunAndReturn(run func(uuid.UUID) (*model.MessagePart, error)) *MockPersistance_GetMessagePartTree_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetAttachment provides a mock function with given fields: _a0, _a1
+func (_m *MockPersistance) GetAttachment(_a0 uuid.UUID, _a1 uuid.UUID) (*model.Attachment, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAttachment")
+	}
+
+	var r0 *model.Attachment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (*model.Attachment, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) *model.Attachment); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Attachment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPersistance_GetAttachment_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetAttachment'
+type MockPersistance_GetAttachment_Call struct {
+	*mock.Call
+}
+
+// GetAttachment is a helper method to define mock.On call
+//   - _a0 uuid.UUID
+//   - _a1 uuid.UUID
+func (_e *MockPersistance_Expecter) GetAttachment(_a0 interface{}, _a1 interface{}) *MockPersistance_GetAttachment_Call {
+	return &MockPersistance_GetAttachment_Call{Call: _e.mock.On("GetAttachment", _a0, _a1)}
+}
+
+func (_c *MockPersistance_GetAttachment_Call) Run(run func(_a0 uuid.UUID, _a1 uuid.UUID)) *MockPersistance_GetAttachment_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPersistance_GetAttachment_Call) Return(_a0 *model.Attachment, _a1 error) *MockPersistance_GetAttachment_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPersistance_GetAttachment_Call) RunAndReturn(run func(uuid.UUID, uuid.UUID) (*model.Attachment, error)) *MockPersistance_GetAttachment_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteMailByID provides a mock function with given fields: _a0
+func (_m *MockPersistance) DeleteMailByID(_a0 uuid.UUID) error {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMailByID")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPersistance_DeleteMailByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteMailByID'
+type MockPersistance_DeleteMailByID_Call struct {
+	*mock.Call
+}
+
+// DeleteMailByID is a helper method to define mock.On call
+//   - _a0 uuid.UUID
+func (_e *MockPersistance_Expecter) DeleteMailByID(_a0 interface{}) *MockPersistance_DeleteMailByID_Call {
+	return &MockPersistance_DeleteMailByID_Call{Call: _e.mock.On("DeleteMailByID", _a0)}
+}
+
+func (_c *MockPersistance_DeleteMailByID_Call) Run(run func(_a0 uuid.UUID)) *MockPersistance_DeleteMailByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockPersistance_DeleteMailByID_Call) Return(_a0 error) *MockPersistance_DeleteMailByID_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPersistance_DeleteMailByID_Call) RunAndReturn(run func(uuid.UUID) error) *MockPersistance_DeleteMailByID_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,43 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeAddress returns the form of an address used for searching. The domain is lowercased and internationalized
+// domain names are converted to punycode, so "josé@Bücher.de" and "josé@xn--bcher-kva.de" both normalize to
+// "josé@xn--bcher-kva.de". The local part is left as is. Input without an "@" is treated as a domain, which lets partial
+// search terms be normalized the same way. A domain that cannot be converted is only lowercased.
+func NormalizeAddress(address string) string {
+	// the local part may be a quoted string containing "@", so split on the last one
+	index := strings.LastIndex(address, "@")
+	if index < 0 {
+		return normalizeDomain(address)
+	}
+
+	return address[:index+1] + normalizeDomain(address[index+1:])
+}
+
+// NormalizeAddresses normalizes every address in the collection.
+func NormalizeAddresses(addresses MailAddressCollection) MailAddressCollection {
+	result := make(MailAddressCollection, 0, len(addresses))
+
+	for _, address := range addresses {
+		result = append(result, NormalizeAddress(address))
+	}
+
+	return result
+}
+
+func normalizeDomain(domain string) string {
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+
+	return strings.ToLower(domain)
+}
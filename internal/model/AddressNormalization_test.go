@@ -0,0 +1,30 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		address  string
+		expected string
+	}{
+		{address: "josé@bücher.de", expected: "josé@xn--bcher-kva.de"},
+		{address: "josé@Bücher.DE", expected: "josé@xn--bcher-kva.de"},
+		{address: "josé@xn--bcher-kva.de", expected: "josé@xn--bcher-kva.de"},
+		{address: "User@Example.com", expected: "User@example.com"},
+		{address: "\"a@b\"@bücher.de", expected: "\"a@b\"@xn--bcher-kva.de"},
+		{address: "bücher.de", expected: "xn--bcher-kva.de"},
+		{address: "<>", expected: "<>"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, model.NormalizeAddress(test.address), test.address)
+	}
+}
@@ -17,33 +17,51 @@ import (
 	"github.com/gofrs/uuid"
 )
 
-// An Attachment is any content embedded in the mail data that is not considered the body.
+// An Attachment is any content embedded in the mail data that is not considered the body. Inline attachments, such as
+// images shown in an HTML body, are referenced from the body by their ContentID.
 type Attachment struct {
 	ID          uuid.UUID `db:"id" json:"id"`
-	MailID      string    `db:"mailId" json:"mailId"`
+	MailItemID  uuid.UUID `db:"mailItemId" json:"mailId"`
 	MailItem    *MailItem `belongs_to:"mailitem" json:"-"`
 	FileName    string    `db:"fileName" json:"fileName"`
 	ContentType string    `db:"contentType" json:"contentType"`
-	Contents    string    `db:"contents" json:"contents"`
+	ContentID   string    `db:"contentId" json:"contentId"`
+	Inline      bool      `db:"inline" json:"inline"`
+	Contents    string    `db:"content" json:"-"`
 	CreatedAt   time.Time `db:"created_at" json:"-"`
 	UpdatedAt   time.Time `db:"updated_at" json:"-"`
 
 	Headers *AttachmentHeader `db:"-" json:"headers"`
 }
 
-// NewAttachment creates a new Attachment object.
+// NewAttachment creates a new Attachment object. The file name and content type are taken from the headers, falling
+// back to the name given in the Content-Type header for parts without a file name.
 func NewAttachment(headers *AttachmentHeader, contents string, xss sanitizer.IXSSServiceProvider) *Attachment {
 	id, _ := uuid.NewV4()
 
+	contentType, params := parseMediaHeader(headers.ContentType)
+
+	fileName := headers.FileName
+	if fileName == "" {
+		fileName = params["name"]
+	}
+
 	return &Attachment{
-		ID:        id,
-		Headers:   headers,
-		Contents:  contents,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          id,
+		FileName:    fileName,
+		ContentType: contentType,
+		Headers:     headers,
+		Contents:    contents,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 }
 
+// TableName overrides the table name pop derives from the type name.
+func (_ Attachment) TableName() string {
+	return "attachment"
+}
+
 // IsContentBase64 returns true/false if the content of this attachment resembles a base64 encoded string.
 func (a *Attachment) IsContentBase64() bool {
 	spaceKiller := func(r rune) rune {
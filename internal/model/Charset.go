@@ -0,0 +1,36 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// DecodeCharset converts content to UTF-8 from the charset named in a Content-Type header value. Content without a
+// charset, or already in UTF-8 or US-ASCII, is returned as is. An error is returned for a charset that is not known.
+func DecodeCharset(content []byte, contentType string) ([]byte, error) {
+	_, params, _ := mime.ParseMediaType(contentType)
+
+	return DecodeCharsetLabel(content, params["charset"])
+}
+
+// DecodeCharsetLabel converts content to UTF-8 from the named charset, as DecodeCharset does.
+func DecodeCharsetLabel(content []byte, label string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return content, nil
+	}
+
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(content))
+	if err != nil {
+		return content, err
+	}
+
+	return io.ReadAll(reader)
+}
@@ -5,6 +5,10 @@
 package model
 
 import (
+	"database/sql/driver"
+	"io"
+	"mime"
+	"net/mail"
 	"strings"
 
 	"github.com/gobuffalo/pop/v6/slices"
@@ -30,3 +34,63 @@ func NewMailAddressCollectionFromStringList(addresses string) MailAddressCollect
 
 	return result
 }
+
+// NewMailAddressCollectionFromHeader parses the value of an address header such as To or Cc into a collection of the
+// addresses it lists. Display names and group names are dropped. A value that cannot be parsed gives an empty
+// collection.
+func NewMailAddressCollectionFromHeader(value string) MailAddressCollection {
+	result := NewMailAddressCollection()
+
+	if strings.TrimSpace(value) == "" {
+		return result
+	}
+
+	addresses, err := addressHeaderParser.ParseList(value)
+	if err != nil {
+		return result
+	}
+
+	for _, address := range addresses {
+		result = append(result, address.Address)
+	}
+
+	return result
+}
+
+// Contains returns true if the collection holds the address. Addresses are compared in their normalized form, ignoring
+// the case of the local part as nearly every mail system does.
+func (c MailAddressCollection) Contains(address string) bool {
+	normalized := NormalizeAddress(address)
+
+	for _, item := range c {
+		if strings.EqualFold(NormalizeAddress(item), normalized) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scan implements the sql.Scanner interface.
+func (c *MailAddressCollection) Scan(src interface{}) error {
+	return (*slices.String)(c).Scan(src)
+}
+
+// Value implements the driver.Valuer interface. An empty collection is stored as an empty list rather than NULL.
+func (c MailAddressCollection) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+
+	return slices.String(c).Value()
+}
+
+// addressHeaderParser decodes encoded words in display names without regard for their charset, which is fine as only
+// the addresses are kept.
+var addressHeaderParser = &mail.AddressParser{
+	WordDecoder: &mime.WordDecoder{
+		CharsetReader: func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		},
+	},
+}
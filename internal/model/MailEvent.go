@@ -0,0 +1,76 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// The types of mail event pushed to clients following changes to the stored mail.
+const (
+	MailReceivedEvent = "mail.received"
+	MailDeletedEvent  = "mail.deleted"
+	MailPrunedEvent   = "mail.pruned"
+)
+
+// MailEvent describes a change to the stored mail. Events for a single mail item carry a summary of it, while pruning
+// events carry the prune code used and the number of mail items removed.
+type MailEvent struct {
+	Type      string       `json:"type"`
+	Mail      *MailSummary `json:"mail,omitempty"`
+	PruneCode string       `json:"pruneCode,omitempty"`
+	Count     int64        `json:"count,omitempty"`
+}
+
+// NewMailReceivedEvent creates the event for a mail item that was received and stored.
+func NewMailReceivedEvent(mailItem *MailItem) MailEvent {
+	return MailEvent{
+		Type: MailReceivedEvent,
+		Mail: NewMailSummary(mailItem),
+	}
+}
+
+// NewMailDeletedEvent creates the event for a single mail item that was deleted.
+func NewMailDeletedEvent(mailItem *MailItem) MailEvent {
+	return MailEvent{
+		Type: MailDeletedEvent,
+		Mail: NewMailSummary(mailItem),
+	}
+}
+
+// NewMailPrunedEvent creates the event for mail deleted in bulk with a prune code.
+func NewMailPrunedEvent(pruneCode string, count int64) MailEvent {
+	return MailEvent{
+		Type:      MailPrunedEvent,
+		PruneCode: pruneCode,
+		Count:     count,
+	}
+}
+
+// MailSummary holds what a mail list needs to show a mail item, leaving out the bodies, headers and attachment
+// contents.
+type MailSummary struct {
+	ID              uuid.UUID             `json:"id"`
+	DateSent        string                `json:"dateSent"`
+	FromAddress     string                `json:"fromAddress"`
+	ToAddresses     MailAddressCollection `json:"toAddresses"`
+	Subject         string                `json:"subject"`
+	AttachmentCount int                   `json:"attachmentCount"`
+	ReceivedAt      time.Time             `json:"receivedAt"`
+}
+
+// NewMailSummary creates the summary of a mail item.
+func NewMailSummary(mailItem *MailItem) *MailSummary {
+	return &MailSummary{
+		ID:              mailItem.ID,
+		DateSent:        mailItem.DateSent,
+		FromAddress:     mailItem.FromAddress,
+		ToAddresses:     mailItem.ToAddresses,
+		Subject:         mailItem.Subject,
+		AttachmentCount: len(mailItem.Attachments),
+		ReceivedAt:      mailItem.CreatedAt,
+	}
+}
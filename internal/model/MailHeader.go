@@ -0,0 +1,63 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"bufio"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// MailHeader is a single header field of a mail item. Headers are kept in the order they were sent, with the name
+// spelled as sent, and a header that appears more than once is stored once per occurrence.
+type MailHeader struct {
+	ID         uuid.UUID `db:"id" json:"-"`
+	MailItemID uuid.UUID `db:"mailItemId" json:"-"`
+	Position   int       `db:"position" json:"-"`
+	Name       string    `db:"name" json:"name"`
+	Value      string    `db:"value" json:"value"`
+	CreatedAt  time.Time `db:"created_at" json:"-"`
+	UpdatedAt  time.Time `db:"updated_at" json:"-"`
+}
+
+// NewMailHeaders reads the header section of a message. Folded values are unfolded and encoded words are decoded.
+// Reading stops at the blank line that ends the headers, or at the first line that is not a header.
+func NewMailHeaders(mailItemID uuid.UUID, message string) []MailHeader {
+	headers := make([]MailHeader, 0)
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(message)))
+
+	for {
+		line, err := reader.ReadContinuedLine()
+		if err != nil || line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			break
+		}
+
+		id, _ := uuid.NewV4()
+
+		headers = append(headers, MailHeader{
+			ID:         id,
+			MailItemID: mailItemID,
+			Position:   len(headers),
+			Name:       strings.TrimSpace(name),
+			Value:      decodeHeader(strings.TrimSpace(value)),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+	}
+
+	return headers
+}
+
+// TableName overrides the table name pop derives from the type name.
+func (_ MailHeader) TableName() string {
+	return "mailheader"
+}
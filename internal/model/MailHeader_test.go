@@ -0,0 +1,45 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestNewMailHeaders(t *testing.T) {
+	t.Parallel()
+
+	mailID := uuid.Must(uuid.NewV4())
+	message := "Message-ID: <1@example.com>\r\n" +
+		"X-Correlation-ID: abc\r\n" +
+		"Received: from a\r\n" +
+		"Received: from b\r\n" +
+		"List-Unsubscribe: <mailto:unsubscribe@example.com>,\r\n" +
+		" <https://example.com/unsubscribe>\r\n" +
+		"Subject: =?UTF-8?B?Y2Fmw6k=?=\r\n" +
+		"\r\n" +
+		"Not-A-Header: body text\r\n"
+
+	headers := model.NewMailHeaders(mailID, message)
+	require.Len(t, headers, 6)
+
+	expected := []struct{ name, value string }{
+		{"Message-ID", "<1@example.com>"},
+		{"X-Correlation-ID", "abc"},
+		{"Received", "from a"},
+		{"Received", "from b"},
+		{"List-Unsubscribe", "<mailto:unsubscribe@example.com>, <https://example.com/unsubscribe>"},
+		{"Subject", "café"},
+	}
+
+	for index, header := range headers {
+		assert.Equal(t, expected[index].name, header.Name)
+		assert.Equal(t, expected[index].value, header.Value)
+		assert.Equal(t, index, header.Position)
+		assert.Equal(t, mailID, header.MailItemID)
+	}
+}
@@ -18,27 +18,52 @@ import (
 
 // MailItem is a struct describing a parsed mail item. This is populated after an incoming client connection has
 // finished sending mail data to this server.
+//
+// FromAddress and ToAddresses are the envelope sender and recipients given with MAIL FROM and RCPT TO. The header
+// address fields are parsed from the message itself, and may list entirely different addresses.
+//
+// TextBody and HTMLBody hold the plain text and HTML alternatives as sent, converted to UTF-8. Body is the one shown
+// when the mail is displayed: the HTML alternative if there is one and the plain text otherwise.
 type MailItem struct {
 	ID               uuid.UUID             `db:"id" json:"id"`
 	DateSent         string                `db:"dateSent" json:"dateSent"`
 	FromAddress      string                `db:"fromAddress" json:"fromAddress"`
 	ToAddresses      MailAddressCollection `db:"toAddresses" json:"toAddresses"`
+	HeaderFrom       MailAddressCollection `db:"headerFrom" json:"headerFrom"`
+	HeaderSender     string                `db:"headerSender" json:"headerSender"`
+	HeaderReplyTo    MailAddressCollection `db:"headerReplyTo" json:"headerReplyTo"`
+	HeaderTo         MailAddressCollection `db:"headerTo" json:"headerTo"`
+	HeaderCc         MailAddressCollection `db:"headerCc" json:"headerCc"`
+	BccAddresses     MailAddressCollection `db:"bccAddresses" json:"bccAddresses"`
 	Subject          string                `db:"subject" json:"subject"`
 	XMailer          string                `db:"xmailer" json:"xmailer"`
 	MIMEVersion      string                `db:"mimeVersion" json:"mimeVersion"`
 	Body             string                `db:"body" json:"body"`
+	TextBody         string                `db:"textBody" json:"textBody"`
+	HTMLBody         string                `db:"htmlBody" json:"htmlBody"`
 	ContentType      string                `db:"contentType" json:"contentType"`
 	Boundary         string                `db:"boundary" json:"boundary"`
 	TransferEncoding string                `db:"transferEncoding" json:"transferEncoding"`
+	AuthUser         string                `db:"authUser" json:"authUser"`
+	HeloDomain       string                `db:"heloDomain" json:"heloDomain"`
 
-	Attachments []*Attachment `has_many:"attachment" json:"-"`
-	CreatedAt   time.Time     `db:"created_at" json:"-"`
-	UpdatedAt   time.Time     `db:"updated_at" json:"-"`
+	// FromAddressNormalized and ToAddressesNormalized hold the envelope addresses with punycode domains for searching.
+	// FromAddress and ToAddresses keep the addresses exactly as received for display.
+	FromAddressNormalized string                `db:"fromAddressNormalized" json:"-"`
+	ToAddressesNormalized MailAddressCollection `db:"toAddressesNormalized" json:"-"`
+
+	Attachments []Attachment `has_many:"attachment" fk_id:"mailItemId" json:"attachments"`
+	Headers     []MailHeader `has_many:"mailheader" fk_id:"mailItemId" order_by:"position asc" json:"headers"`
+	CreatedAt   time.Time    `db:"created_at" json:"-"`
+	UpdatedAt   time.Time    `db:"updated_at" json:"-"`
 
 	Message           *SMTPMessagePart `db:"-" json:"-"`
-	InlineAttachments []*Attachment    `db:"-" json:"-"`
-	TextBody          string           `db:"-" json:"-"`
-	HTMLBody          string           `db:"-" json:"-"`
+	InlineAttachments []Attachment     `db:"-" json:"-"`
+
+	// Raw is the message exactly as received. It is stored apart from the mail item as a RawMessage.
+	Raw []byte `db:"-" json:"-"`
+	// PartTree is the MIME structure of the message. Its parts are stored apart from the mail item.
+	PartTree *MessagePart `db:"-" json:"-"`
 }
 
 // NewEmptyMailItem creates an empty mail object.
@@ -46,12 +71,18 @@ func NewEmptyMailItem(logger *slog.Logger) *MailItem {
 	id, _ := uuid.NewV4()
 
 	result := &MailItem{
-		ID:          id,
-		ToAddresses: NewMailAddressCollection(),
-		Attachments: make([]*Attachment, 0, 5),
-		Message:     NewSMTPMessagePart(logger),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:            id,
+		ToAddresses:   NewMailAddressCollection(),
+		HeaderFrom:    NewMailAddressCollection(),
+		HeaderReplyTo: NewMailAddressCollection(),
+		HeaderTo:      NewMailAddressCollection(),
+		HeaderCc:      NewMailAddressCollection(),
+		BccAddresses:  NewMailAddressCollection(),
+		Attachments:   make([]Attachment, 0, 5),
+		Headers:       make([]MailHeader, 0),
+		Message:       NewSMTPMessagePart(logger),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
 	return result
@@ -64,7 +95,7 @@ func NewMailItem(
 	fromAddress string,
 	toAddresses MailAddressCollection,
 	subject, xMailer, body, contentType, boundary string,
-	attachments []*Attachment,
+	attachments []Attachment,
 	logger *slog.Logger,
 ) *MailItem {
 	return &MailItem{
@@ -85,6 +116,33 @@ func NewMailItem(
 	}
 }
 
+// SetHeaderAddresses fills in the header address fields from the parsed message. BccAddresses lists the envelope
+// recipients missing from the To and Cc headers, which is how a blind copy is delivered, along with any addresses in a
+// Bcc header the client left in place.
+func (m *MailItem) SetHeaderAddresses(message ISMTPMessagePart) {
+	m.HeaderFrom = NewMailAddressCollectionFromHeader(message.GetHeader("From"))
+	m.HeaderReplyTo = NewMailAddressCollectionFromHeader(message.GetHeader("Reply-To"))
+	m.HeaderTo = NewMailAddressCollectionFromHeader(message.GetHeader("To"))
+	m.HeaderCc = NewMailAddressCollectionFromHeader(message.GetHeader("Cc"))
+	m.BccAddresses = NewMailAddressCollectionFromHeader(message.GetHeader("Bcc"))
+	m.HeaderSender = ""
+
+	if sender := NewMailAddressCollectionFromHeader(message.GetHeader("Sender")); len(sender) > 0 {
+		m.HeaderSender = sender[0]
+	}
+
+	for _, recipient := range m.ToAddresses {
+		if !m.HeaderTo.Contains(recipient) && !m.HeaderCc.Contains(recipient) && !m.BccAddresses.Contains(recipient) {
+			m.BccAddresses = append(m.BccAddresses, recipient)
+		}
+	}
+}
+
+// TableName overrides the table name pop derives from the type name.
+func (_ MailItem) TableName() string {
+	return "mailitem"
+}
+
 // Render implements the render.Renderer interface for use with chi-router.
 func (_ *MailItem) Render(_ http.ResponseWriter, _ *http.Request) error {
 	return nil
@@ -101,12 +159,21 @@ func (m *MailItem) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	), nil
 }
 
+// BeforeSave gets run by pop before the mail item is created or updated, and fills in the normalized addresses.
+func (m *MailItem) BeforeSave(_ *pop.Connection) error {
+	m.FromAddressNormalized = NormalizeAddress(m.FromAddress)
+	m.ToAddressesNormalized = NormalizeAddresses(m.ToAddresses)
+
+	return nil
+}
+
+// Sanitize cleans the fields shown as text in the UI. The bodies are left as sent, and are only ever shown sanitized or
+// in a sandboxed preview.
 func (m *MailItem) Sanitize(xss sanitizer.IXSSServiceProvider) {
 	m.Subject = xss.SanitizeString(m.Subject)
 	m.XMailer = xss.SanitizeString(m.XMailer)
-	m.Body = xss.SanitizeString(m.Body)
 
-	for _, att := range m.Attachments {
-		att.Sanitize(xss)
+	for index := range m.Attachments {
+		m.Attachments[index].Sanitize(xss)
 	}
 }
@@ -0,0 +1,168 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// MessagePart is a single node of the MIME tree of a mail item. Multipart nodes list their children in Parts, in the
+// order they appear in the message. Every node keeps its own content so that it can be downloaded by itself: leaf
+// content is stored with the transfer encoding removed, while multipart content is stored as received, boundaries and
+// all.
+type MessagePart struct {
+	ID                 uuid.UUID     `db:"id" json:"id"`
+	MailItemID         uuid.UUID     `db:"mailItemId" json:"-"`
+	ParentID           uuid.NullUUID `db:"parentId" json:"-"`
+	Position           int           `db:"position" json:"-"`
+	ContentType        string        `db:"contentType" json:"contentType"`
+	Charset            string        `db:"charset" json:"charset"`
+	ContentDisposition string        `db:"contentDisposition" json:"contentDisposition"`
+	FileName           string        `db:"fileName" json:"fileName"`
+	TransferEncoding   string        `db:"transferEncoding" json:"transferEncoding"`
+	ContentID          string        `db:"contentId" json:"contentId"`
+	Size               int           `db:"size" json:"size"`
+	Content            []byte        `db:"content" json:"-"`
+	CreatedAt          time.Time     `db:"created_at" json:"-"`
+	UpdatedAt          time.Time     `db:"updated_at" json:"-"`
+
+	Parts []*MessagePart `db:"-" json:"parts"`
+}
+
+// NewMessagePartTree builds the part tree of a parsed message. Header values that cannot be parsed are kept as sent,
+// and content that cannot be decoded is stored as received, since malformed messages are what the tree is most
+// often looked at for.
+func NewMessagePartTree(mailItemID uuid.UUID, message ISMTPMessagePart) *MessagePart {
+	return newMessagePart(mailItemID, uuid.NullUUID{}, 0, message)
+}
+
+func newMessagePart(mailItemID uuid.UUID, parentID uuid.NullUUID, position int, message ISMTPMessagePart) *MessagePart {
+	id, _ := uuid.NewV4()
+
+	part := &MessagePart{
+		ID:               id,
+		MailItemID:       mailItemID,
+		ParentID:         parentID,
+		Position:         position,
+		TransferEncoding: strings.ToLower(strings.TrimSpace(message.GetHeader("Content-Transfer-Encoding"))),
+		ContentID:        strings.Trim(strings.TrimSpace(message.GetHeader("Content-ID")), "<>"),
+		Parts:            make([]*MessagePart, 0),
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+
+	var typeParams, dispositionParams map[string]string
+
+	part.ContentType, typeParams = parseMediaHeader(message.GetHeader("Content-Type"))
+	part.ContentDisposition, dispositionParams = parseMediaHeader(message.GetContentDisposition())
+	part.Charset = strings.ToLower(typeParams["charset"])
+
+	part.FileName = dispositionParams["filename"]
+	if part.FileName == "" {
+		part.FileName = typeParams["name"]
+	}
+
+	if part.IsMultipart() {
+		part.Content = []byte(message.GetBody())
+	} else if content, err := message.GetDecodedBody(); err == nil {
+		part.Content = content
+	} else {
+		part.Content = []byte(message.GetBody())
+	}
+
+	part.Size = len(part.Content)
+
+	for index, child := range message.GetMessageParts() {
+		part.Parts = append(part.Parts, newMessagePart(mailItemID, uuid.NullUUID{UUID: id, Valid: true}, index, child))
+	}
+
+	return part
+}
+
+// BuildMessagePartTree links a flat list of stored parts back into a tree and returns the root. This returns nil if
+// the list holds no root part.
+func BuildMessagePartTree(parts []MessagePart) *MessagePart {
+	var root *MessagePart
+
+	byID := make(map[uuid.UUID]*MessagePart, len(parts))
+
+	for index := range parts {
+		parts[index].Parts = make([]*MessagePart, 0)
+		byID[parts[index].ID] = &parts[index]
+	}
+
+	for index := range parts {
+		part := &parts[index]
+
+		if !part.ParentID.Valid {
+			root = part
+
+			continue
+		}
+
+		if parent, ok := byID[part.ParentID.UUID]; ok {
+			parent.Parts = append(parent.Parts, part)
+		}
+	}
+
+	for _, part := range byID {
+		sort.Slice(part.Parts, func(i, j int) bool {
+			return part.Parts[i].Position < part.Parts[j].Position
+		})
+	}
+
+	return root
+}
+
+// Flatten returns the part and all of its descendants, parents before their children.
+func (p *MessagePart) Flatten() []*MessagePart {
+	result := []*MessagePart{p}
+
+	for _, child := range p.Parts {
+		result = append(result, child.Flatten()...)
+	}
+
+	return result
+}
+
+// IsMultipart returns true if the part is a container for other parts.
+func (p *MessagePart) IsMultipart() bool {
+	return strings.HasPrefix(p.ContentType, "multipart/")
+}
+
+// TableName overrides the table name pop derives from the type name.
+func (_ MessagePart) TableName() string {
+	return "messagepart"
+}
+
+// Render implements the render.Renderer interface for use with chi-router.
+func (_ *MessagePart) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+// parseMediaHeader splits a Content-Type or Content-Disposition value into its lower cased value and parameters. A
+// header without a parsable parameter list still yields the value in front of the first semicolon.
+func parseMediaHeader(header string) (string, map[string]string) {
+	if strings.TrimSpace(header) == "" {
+		return "", map[string]string{}
+	}
+
+	value, params, err := mime.ParseMediaType(header)
+	if err != nil && value == "" {
+		value, _, _ = strings.Cut(header, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	return value, params
+}
@@ -0,0 +1,98 @@
+package model_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+const relatedMessage = "Subject: Tree\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/related; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=\"ISO-8859-1\"\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"caf=E9\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<img src=\"cid:logo@example.com\">\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: image/png; name=\"logo.png\"\r\n" +
+	"Content-Disposition: inline\r\n" +
+	"Content-ID: <logo@example.com>\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0K\r\n" +
+	"GgoAAAA=\r\n" +
+	"--outer--\r\n"
+
+func TestNewMessagePartTree(t *testing.T) {
+	t.Parallel()
+
+	message := model.NewSMTPMessagePart(slog.New(slog.DiscardHandler))
+	require.NoError(t, message.BuildMessages(relatedMessage))
+
+	mailID := uuid.Must(uuid.NewV4())
+	root := model.NewMessagePartTree(mailID, message)
+
+	assert.Equal(t, "multipart/related", root.ContentType)
+	assert.False(t, root.ParentID.Valid)
+	require.Len(t, root.Parts, 2)
+
+	alternative := root.Parts[0]
+	assert.Equal(t, "multipart/alternative", alternative.ContentType)
+	assert.Equal(t, root.ID, alternative.ParentID.UUID)
+	require.Len(t, alternative.Parts, 2)
+
+	text := alternative.Parts[0]
+	assert.Equal(t, "text/plain", text.ContentType)
+	assert.Equal(t, "iso-8859-1", text.Charset)
+	assert.Equal(t, "quoted-printable", text.TransferEncoding)
+	assert.Equal(t, []byte("caf\xe9"), text.Content)
+	assert.Equal(t, 4, text.Size)
+
+	html := alternative.Parts[1]
+	assert.Equal(t, "text/html", html.ContentType)
+	assert.Equal(t, 1, html.Position)
+
+	image := root.Parts[1]
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, "inline", image.ContentDisposition)
+	assert.Equal(t, "logo.png", image.FileName)
+	assert.Equal(t, "logo@example.com", image.ContentID)
+	assert.Equal(t, "base64", image.TransferEncoding)
+	assert.Equal(t, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00"), image.Content)
+
+	flat := root.Flatten()
+	require.Len(t, flat, 5)
+
+	for _, part := range flat {
+		assert.Equal(t, mailID, part.MailItemID)
+	}
+
+	// stored parts come back in any order
+	stored := make([]model.MessagePart, 0, len(flat))
+	for index := len(flat) - 1; index >= 0; index-- {
+		stored = append(stored, *flat[index])
+	}
+
+	rebuilt := model.BuildMessagePartTree(stored)
+	require.NotNil(t, rebuilt)
+	assert.Equal(t, root.ID, rebuilt.ID)
+	require.Len(t, rebuilt.Parts, 2)
+	assert.Equal(t, alternative.ID, rebuilt.Parts[0].ID)
+	assert.Equal(t, text.ID, rebuilt.Parts[0].Parts[0].ID)
+	assert.Equal(t, html.ID, rebuilt.Parts[0].Parts[1].ID)
+}
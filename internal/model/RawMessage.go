@@ -0,0 +1,34 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// RawMessage holds the exact bytes of a message as received with DATA or BDAT, after dot-unstuffing. It shares its ID
+// with the mail item it belongs to, and is kept in its own table so that listing mail does not load every message.
+type RawMessage struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	Content   []byte    `db:"content" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"-"`
+}
+
+// NewRawMessage creates the raw message for a mail item.
+func NewRawMessage(mailItem *MailItem) *RawMessage {
+	return &RawMessage{
+		ID:        mailItem.ID,
+		Content:   mailItem.Raw,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// TableName overrides the table name pop derives from the type name.
+func (_ RawMessage) TableName() string {
+	return "rawmessage"
+}
@@ -6,11 +6,13 @@ package model
 
 import (
 	"bufio"
+	"encoding/base64"
 	"io"
 	"io/ioutil"
 	"log/slog"
 	"mime"
 	"mime/multipart"
+	"mime/quotedprintable"
 	"net/mail"
 	"net/textproto"
 	"strings"
@@ -35,6 +37,7 @@ type ISMTPMessagePart interface {
 	GetBoundaryFromHeaderString(header string) (string, error)
 	GetContentDisposition() string
 	GetContentType() string
+	GetDecodedBody() ([]byte, error)
 	GetFilenameFromContentDisposition() string
 	GetHeader(key string) string
 	GetMessageParts() []ISMTPMessagePart
@@ -85,24 +88,35 @@ func (messagePart *SMTPMessagePart) AddHeaders(headers textproto.MIMEHeader) err
 }
 
 /*
-BuildMessages pulls the message body from the data transmission
-and stores the whole body. If the message type is multipart it then
-attempts to parse the parts.
+BuildMessages pulls the headers and body from the data transmission
+and stores the body that follows the headers. If the message type is
+multipart it then attempts to parse the parts.
 */
 func (messagePart *SMTPMessagePart) BuildMessages(body string) error {
 	var err error
 	var isMultipart bool
 	var boundary string
 	var headers textproto.MIMEHeader
+	var remaining []byte
 
 	headerReader := textproto.NewReader(bufio.NewReader(strings.NewReader(body)))
 
-	if headers, err = headerReader.ReadMIMEHeader(); err != nil {
+	/*
+	 * A message made up of nothing but headers ends without the blank
+	 * line that would start the body. It simply has an empty body.
+	 */
+	if headers, err = headerReader.ReadMIMEHeader(); err != nil && err != io.EOF {
 		return errors.Wrap(err, "Problem reading headers")
 	}
 
 	messagePart.AddHeaders(headers)
 
+	if remaining, err = io.ReadAll(headerReader.R); err != nil {
+		return errors.Wrap(err, "Problem reading body")
+	}
+
+	body = string(remaining)
+
 	/*
 	 * If this is not a multipart message, bail early. We've got
 	 * what we need.
@@ -112,7 +126,7 @@ func (messagePart *SMTPMessagePart) BuildMessages(body string) error {
 	}
 
 	if !isMultipart {
-		messagePart.logger.Debug("Body of message: %s", body)
+		messagePart.logger.Debug("Body of message", "body", body)
 
 		if err = messagePart.AddBody(body); err != nil {
 			return errors.Wrapf(err, "Error adding body to message part")
@@ -125,7 +139,7 @@ func (messagePart *SMTPMessagePart) BuildMessages(body string) error {
 		return errors.Wrapf(err, "Error getting boundary for message part")
 	}
 
-	messagePart.logger.Debug("Body of message: %s", body)
+	messagePart.logger.Debug("Body of message", "body", body)
 	if err = messagePart.AddBody(body); err != nil {
 		return errors.Wrapf(err, "Error adding body to message part")
 	}
@@ -142,7 +156,7 @@ func (messagePart *SMTPMessagePart) GetBody() string {
 
 	if messagePart.body == "" {
 		if bytes, err = ioutil.ReadAll(messagePart.Message.Body); err != nil {
-			messagePart.logger.Error("Problem reading message body: %s", err.Error())
+			messagePart.logger.Error("Problem reading message body", "error", err)
 			return ""
 		}
 
@@ -152,6 +166,25 @@ func (messagePart *SMTPMessagePart) GetBody() string {
 	return messagePart.body
 }
 
+/*
+GetDecodedBody returns the body with its Content-Transfer-Encoding
+removed. Bodies in an identity encoding such as 7bit are returned as is.
+*/
+func (messagePart *SMTPMessagePart) GetDecodedBody() ([]byte, error) {
+	body := messagePart.GetBody()
+
+	switch strings.ToLower(strings.TrimSpace(messagePart.Message.Header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.StdEncoding.DecodeString(strings.Map(removeWhitespace, body))
+
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+
+	default:
+		return []byte(body), nil
+	}
+}
+
 /*
 GetFilenameFromContentDisposition returns a filename from a Content-Disposition header
 */
@@ -174,14 +207,7 @@ func (messagePart *SMTPMessagePart) GetFilenameFromContentDisposition() string {
 GetHeader returns the value of a specified header key
 */
 func (messagePart *SMTPMessagePart) GetHeader(key string) string {
-	decoder := new(mime.WordDecoder)
-	decoder.CharsetReader = func(headerCharset string, input io.Reader) (io.Reader, error) {
-		encoding, _ := charset.Lookup(headerCharset)
-		return encoding.NewDecoder().Reader(input), nil
-	}
-
-	result, _ := decoder.DecodeHeader(messagePart.Message.Header.Get(key))
-	return result
+	return decodeHeader(messagePart.Message.Header.Get(key))
 }
 
 /*
@@ -202,7 +228,8 @@ func (messagePart *SMTPMessagePart) ParseMessages(body string, boundary string)
 	reader := multipart.NewReader(strings.NewReader(body), boundary)
 
 	for {
-		part, err = reader.NextPart()
+		// raw parts keep their Content-Transfer-Encoding header, which NextPart removes for quoted-printable bodies
+		part, err = reader.NextRawPart()
 
 		switch err {
 		case io.EOF:
@@ -215,7 +242,7 @@ func (messagePart *SMTPMessagePart) ParseMessages(body string, boundary string)
 			}
 
 			innerBody := string(bodyPart)
-			messagePart.logger.Debug("Building new message part: %v", part.Header)
+			messagePart.logger.Debug("Building new message part", "header", part.Header)
 
 			if boundary, err = messagePart.GetBoundaryFromHeaderString(part.Header.Get("Content-Type")); err != nil {
 				return errors.Wrapf(err, "Error getting boundary marker")
@@ -225,7 +252,10 @@ func (messagePart *SMTPMessagePart) ParseMessages(body string, boundary string)
 			newMessage.Message.Header = messagePart.convertPartHeadersToMap(part.Header)
 			newMessage.Message.Body = strings.NewReader(innerBody)
 
-			newMessage.ParseMessages(innerBody, boundary)
+			if boundary != "" {
+				newMessage.ParseMessages(innerBody, boundary)
+			}
+
 			messagePart.MessageParts = append(messagePart.MessageParts, newMessage)
 
 		default:
@@ -254,6 +284,11 @@ func (messagePart *SMTPMessagePart) GetBoundary() (string, error) {
 GetBoundaryFromHeaderString returns the boundary marker defined in the header
 */
 func (messagePart *SMTPMessagePart) GetBoundaryFromHeaderString(header string) (string, error) {
+	// parts without a Content-Type are plain text (RFC 2045, section 5.2)
+	if strings.TrimSpace(header) == "" {
+		return "", nil
+	}
+
 	_, params, err := mime.ParseMediaType(header)
 	if err != nil {
 		return "", err
@@ -290,6 +325,35 @@ func (messagePart *SMTPMessagePart) parseContentType() (string, string, error) {
 	return mediaType, params["boundary"], nil
 }
 
+// decodeHeader decodes any RFC 2047 encoded words in a header value. The value is returned as is if it cannot be
+// decoded, such as when it names an unknown charset.
+func decodeHeader(value string) string {
+	decoder := new(mime.WordDecoder)
+	decoder.CharsetReader = func(headerCharset string, input io.Reader) (io.Reader, error) {
+		encoding, _ := charset.Lookup(headerCharset)
+		if encoding == nil {
+			return nil, errors.Errorf("unknown charset %q", headerCharset)
+		}
+
+		return encoding.NewDecoder().Reader(input), nil
+	}
+
+	result, err := decoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return result
+}
+
+func removeWhitespace(r rune) rune {
+	if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+		return -1
+	}
+
+	return r
+}
+
 func (messagePart *SMTPMessagePart) convertPartHeadersToMap(partHeaders textproto.MIMEHeader) map[string][]string {
 	convertedHeaders := make(map[string][]string)
 	for key, value := range partHeaders {
@@ -0,0 +1,49 @@
+package model_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+func TestSMTPMessagePart_BuildMessages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		message string
+		subject string
+		body    string
+		parts   int
+	}{
+		{name: "crlf", message: "Subject: Hi\r\n\r\nBody\r\n", subject: "Hi", body: "Body\r\n"},
+		{name: "bare lf", message: "Subject: Hi\n\nBody\n", subject: "Hi", body: "Body\n"},
+		{name: "headers only", message: "Subject: Hi\r\n", subject: "Hi"},
+		{name: "headers and blank line", message: "Subject: Hi\r\n\r\n", subject: "Hi"},
+		{
+			name: "multipart with bare lf",
+			message: "Subject: Hi\nContent-Type: multipart/alternative; boundary=b\n\n" +
+				"--b\nContent-Type: text/plain\n\nText\n--b\nContent-Type: text/html\n\n<p>HTML</p>\n--b--\n",
+			subject: "Hi",
+			body:    "--b\nContent-Type: text/plain\n\nText\n--b\nContent-Type: text/html\n\n<p>HTML</p>\n--b--\n",
+			parts:   2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			message := model.NewSMTPMessagePart(slog.New(slog.DiscardHandler))
+			require.NoError(t, message.BuildMessages(test.message))
+
+			assert.Equal(t, test.subject, message.GetHeader("Subject"))
+			assert.Equal(t, test.body, message.GetBody())
+			assert.Len(t, message.GetMessageParts(), test.parts)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MailCursor marks a position in the mail collection by the time a mail item was received and its ID. The zero cursor
+// marks the start of the collection.
+type MailCursor struct {
+	ReceivedAt time.Time
+	ID         uuid.UUID
+}
+
+// NewMailCursor returns the cursor pointing at a mail item.
+func NewMailCursor(item *model.MailItem) *MailCursor {
+	return &MailCursor{
+		ReceivedAt: item.CreatedAt,
+		ID:         item.ID,
+	}
+}
+
+// ParseMailCursor decodes a cursor created with String. An empty value is the zero cursor.
+func ParseMailCursor(value string) (*MailCursor, error) {
+	if value == "" {
+		return &MailCursor{}, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	receivedAt, id, ok := strings.Cut(string(decoded), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &MailCursor{}
+
+	if cursor.ReceivedAt, err = time.Parse(time.RFC3339Nano, receivedAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.ID, err = uuid.FromString(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// IsZero returns true if the cursor marks the start of the collection.
+func (c *MailCursor) IsZero() bool {
+	return c.ID == uuid.Nil
+}
+
+// String encodes the cursor as an opaque, URL safe value. The zero cursor is encoded as an empty string.
+func (c *MailCursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(c.ReceivedAt.Format(time.RFC3339Nano) + "|" + c.ID.String()))
+}
@@ -0,0 +1,37 @@
+package persistence_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+func TestMailCursor(t *testing.T) {
+	t.Parallel()
+
+	item := &model.MailItem{
+		ID:        uuid.Must(uuid.NewV4()),
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.FixedZone("", 2*60*60)),
+	}
+
+	cursor, err := persistence.ParseMailCursor(persistence.NewMailCursor(item).String())
+	require.NoError(t, err)
+	assert.Equal(t, item.ID, cursor.ID)
+	assert.True(t, item.CreatedAt.Equal(cursor.ReceivedAt))
+
+	cursor, err = persistence.ParseMailCursor("")
+	require.NoError(t, err)
+	assert.True(t, cursor.IsZero())
+	assert.Equal(t, "", cursor.String())
+
+	for _, value := range []string{"not a cursor!", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		_, err = persistence.ParseMailCursor(value)
+		assert.ErrorIs(t, err, persistence.ErrInvalidCursor, value)
+	}
+}
@@ -4,16 +4,191 @@
 
 package persistence
 
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+// headerSearchPrefix marks a header term in a free text search, as in "header:X-Correlation-ID=abc".
+const headerSearchPrefix = "header:"
+
 /*
 MailSearch is a set of criteria used to filter a mail collection
 */
 type MailSearch struct {
 	Message string
+	Subject string
 	Start   string
 	End     string
 	From    string
 	To      string
 
+	// Headers limits the search to mail carrying every one of the headers.
+	Headers []HeaderSearch
+
 	OrderByField     string
 	OrderByDirection string
+
+	// Cursor pages through mail by the time it was received, newest first, starting after the mail the cursor points
+	// at. Since lists the mail received after the mail the cursor points at, oldest first. Either may be the zero cursor
+	// to start from the newest or oldest mail. The order fields are ignored when either is set.
+	Cursor *MailCursor
+	Since  *MailCursor
+
+	// ReceivedBefore limits a Since search to the mail received before it, unless it is the zero time. Mail received
+	// since then may still be committing behind mail received after it, and a poll must not move past it.
+	ReceivedBefore time.Time
+}
+
+// Matches returns true if a mail item meets the filter criteria of the search, as the same search of stored mail would
+// find it. Text is matched without regard to case. The order and cursor fields are ignored.
+func (s *MailSearch) Matches(item *model.MailItem) bool {
+	if strings.TrimSpace(s.Message) != "" && !containsFold(item.Body, s.Message) && !containsFold(item.Subject, s.Message) {
+		return false
+	}
+
+	if strings.TrimSpace(s.Subject) != "" && !containsFold(item.Subject, s.Subject) {
+		return false
+	}
+
+	if strings.TrimSpace(s.From) != "" && !matchesAddress(item.FromAddress, s.From) {
+		return false
+	}
+
+	if strings.TrimSpace(s.To) != "" {
+		found := false
+
+		for _, address := range item.ToAddresses {
+			if matchesAddress(address, s.To) {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if date, err := time.Parse("2006-01-02", strings.TrimSpace(s.Start)); err == nil {
+		if item.DateSent < date.Format("2006-01-02") {
+			return false
+		}
+	}
+
+	if date, err := time.Parse("2006-01-02", strings.TrimSpace(s.End)); err == nil {
+		if item.DateSent >= date.Add(time.Hour*24).Format("2006-01-02") {
+			return false
+		}
+	}
+
+	for _, header := range s.Headers {
+		if !header.Matches(item.Headers) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HeaderSearch matches mail carrying a header. The name is matched without regard to case and the value exactly. An
+// empty value matches any value.
+type HeaderSearch struct {
+	Name  string
+	Value string
+}
+
+// Matches returns true if one of the headers has the name and value searched for.
+func (h HeaderSearch) Matches(headers []model.MailHeader) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, h.Name) && (h.Value == "" || header.Value == h.Value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseHeaderSearch parses a header criterion written as "Name=value". A name alone matches any value. This returns
+// false if the name is empty.
+func ParseHeaderSearch(term string) (HeaderSearch, bool) {
+	name, value, _ := strings.Cut(term, "=")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return HeaderSearch{}, false
+	}
+
+	return HeaderSearch{Name: name, Value: strings.TrimSpace(value)}, true
+}
+
+// ParseMessageSearch splits header terms such as header:X-Correlation-ID=abc out of a free text search and returns the
+// rest of the text along with the header criteria. Values holding spaces may be quoted, as in header:X-Trace="a b".
+// Text without header terms is returned unchanged.
+func ParseMessageSearch(message string) (string, []HeaderSearch) {
+	var (
+		headers []HeaderSearch
+		rest    []string
+	)
+
+	for _, term := range splitSearchTerms(message) {
+		if len(term) > len(headerSearchPrefix) && strings.EqualFold(term[:len(headerSearchPrefix)], headerSearchPrefix) {
+			if header, ok := ParseHeaderSearch(strings.ReplaceAll(term[len(headerSearchPrefix):], `"`, "")); ok {
+				headers = append(headers, header)
+
+				continue
+			}
+		}
+
+		rest = append(rest, term)
+	}
+
+	if len(headers) == 0 {
+		return message, nil
+	}
+
+	return strings.Join(rest, " "), headers
+}
+
+// matchesAddress returns true if the address holds the search text, as received or with its domain in punycode.
+func matchesAddress(address, search string) bool {
+	return containsFold(address, search) || containsFold(model.NormalizeAddress(address), model.NormalizeAddress(search))
+}
+
+func containsFold(text, search string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(search))
+}
+
+// splitSearchTerms splits search text on white space outside of double quotes. Quotes are kept in the terms.
+func splitSearchTerms(text string) []string {
+	var (
+		terms   []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+
+	return terms
 }
@@ -0,0 +1,86 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+func TestParseMessageSearch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		search   string
+		message  string
+		expected []persistence.HeaderSearch
+	}{
+		{search: "invoice  due", message: "invoice  due"},
+		{
+			search:   "header:X-Correlation-ID=abc",
+			message:  "",
+			expected: []persistence.HeaderSearch{{Name: "X-Correlation-ID", Value: "abc"}},
+		},
+		{
+			search:  `invoice HEADER:X-Trace="a b" header:List-Unsubscribe`,
+			message: "invoice",
+			expected: []persistence.HeaderSearch{
+				{Name: "X-Trace", Value: "a b"},
+				{Name: "List-Unsubscribe"},
+			},
+		},
+		{search: "header:=abc", message: "header:=abc"},
+	}
+
+	for _, test := range tests {
+		message, headers := persistence.ParseMessageSearch(test.search)
+
+		assert.Equal(t, test.message, message, test.search)
+		assert.Equal(t, test.expected, headers, test.search)
+	}
+}
+
+func TestMailSearch_Matches(t *testing.T) {
+	t.Parallel()
+
+	item := &model.MailItem{
+		FromAddress: "noreply@bücher.example",
+		ToAddresses: model.MailAddressCollection{"bob@example.com", "alice@x.test"},
+		Subject:     "Reset your password",
+		Body:        "Click the link",
+		DateSent:    "2026-01-02 10:00:00",
+		Headers:     []model.MailHeader{{Name: "X-Correlation-ID", Value: "abc"}},
+	}
+
+	tests := []struct {
+		name     string
+		search   persistence.MailSearch
+		expected bool
+	}{
+		{name: "empty search", search: persistence.MailSearch{}, expected: true},
+		{name: "recipient and subject", search: persistence.MailSearch{To: "ALICE@x.test", Subject: "reset"}, expected: true},
+		{name: "other recipient", search: persistence.MailSearch{To: "carol@x.test"}, expected: false},
+		{name: "message in body", search: persistence.MailSearch{Message: "link"}, expected: true},
+		{name: "subject not in body", search: persistence.MailSearch{Subject: "link"}, expected: false},
+		{name: "punycode sender", search: persistence.MailSearch{From: "xn--bcher-kva.example"}, expected: true},
+		{name: "within dates", search: persistence.MailSearch{Start: "2026-01-02", End: "2026-01-02"}, expected: true},
+		{name: "before start", search: persistence.MailSearch{Start: "2026-01-03"}, expected: false},
+		{name: "after end", search: persistence.MailSearch{End: "2026-01-01"}, expected: false},
+		{
+			name:     "header",
+			search:   persistence.MailSearch{Headers: []persistence.HeaderSearch{{Name: "x-correlation-id", Value: "abc"}}},
+			expected: true,
+		},
+		{
+			name:     "other header value",
+			search:   persistence.MailSearch{Headers: []persistence.HeaderSearch{{Name: "X-Correlation-ID", Value: "ABC"}}},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.search.Matches(item), test.name)
+	}
+}
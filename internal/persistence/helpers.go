@@ -5,50 +5,42 @@
 package persistence
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/gobuffalo/pop/v6"
-	"github.com/gofrs/uuid"
 
 	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
 
-func getMailAndAttachmentsQuery(whereClause string) string {
-	sqlQuery := `
-		SELECT
-			  mailitem.dateSent
-			, mailitem.fromAddress
-			, mailitem.toAddressList
-			, mailitem.subject
-			, mailitem.xmailer
-			, mailitem.body
-			, mailitem.contentType
-			, mailitem.boundary
-			, attachment.id AS attachmentID
-			, attachment.fileName
-			, attachment.contentType
-
-		FROM mailitem
-			LEFT JOIN attachment ON attachment.mailItemID=mailitem.id
-
-		WHERE 1=1 `
-
-	sqlQuery = sqlQuery + whereClause
-	sqlQuery = sqlQuery + ` ORDER BY mailitem.dateSent DESC `
-
-	return sqlQuery
+// messagePartSummaryColumns are the message part columns needed to describe the part tree, leaving out the content.
+var messagePartSummaryColumns = []string{
+	"id",
+	"mailItemId",
+	"parentId",
+	"position",
+	"contentType",
+	"charset",
+	"contentDisposition",
+	"fileName",
+	"transferEncoding",
+	"contentId",
+	"size",
+	"created_at",
+	"updated_at",
 }
 
-func getMailCountQuery(mailSearch *MailSearch) (string, []interface{}) {
-	sqlQuery := `
-		SELECT COUNT(id) AS mailItemCount FROM mailitem WHERE 1=1
-	`
-
-	var parameters []interface{}
-	return addSearchCriteria(sqlQuery, parameters, mailSearch)
+// attachmentSummaryColumns are the attachment columns shown in a mail listing, leaving out the content.
+var attachmentSummaryColumns = []string{
+	"id",
+	"mailItemId",
+	"fileName",
+	"contentType",
+	"contentId",
+	"inline",
+	"created_at",
+	"updated_at",
 }
 
 func getDeleteAttachmentsQuery(startDate string) string {
@@ -62,210 +54,180 @@ func getDeleteAttachmentsQuery(startDate string) string {
 	return sqlQuery
 }
 
-func getDeleteMailQuery(startDate string) string {
+func getDeleteRawMessagesQuery(startDate string) string {
 	where := ""
 
 	if len(startDate) > 0 {
-		where = where + " AND dateSent <= ? "
+		where = where + " AND mailitem.dateSent <= ? "
 	}
 
-	sqlQuery := "DELETE FROM mailitem WHERE 1=1" + where
+	sqlQuery := "DELETE FROM rawmessage WHERE rawmessage.id IN (SELECT mailitem.id FROM mailitem WHERE 1=1 " + where + ")"
 	return sqlQuery
 }
 
-func getInsertMailQuery() string {
-	sqlQuery := `
-		INSERT INTO mailitem (
-			  id
-			, dateSent
-			, fromAddress
-			, toAddressList
-			, subject
-			, xmailer
-			, body
-			, contentType
-			, boundary
-		) VALUES (
-			  ?
-			, ?
-			, ?
-			, ?
-			, ?
-			, ?
-			, ?
-			, ?
-			, ?
-		)
-	`
-
-	return sqlQuery
-}
+func getDeleteMessagePartsQuery(startDate string) string {
+	where := ""
 
-func getInsertAttachmentQuery() string {
-	sqlQuery := `
-		INSERT INTO attachment (
-			  id
-			, mailItemId
-			, fileName
-			, contentType
-			, content
-		) VALUES (
-			  ?
-			, ?
-			, ?
-			, ?
-			, ?
-		)
-	`
+	if len(startDate) > 0 {
+		where = where + " AND mailitem.dateSent <= ? "
+	}
 
+	sqlQuery := "DELETE FROM messagepart WHERE messagepart.mailItemId IN (SELECT mailitem.id FROM mailitem WHERE 1=1 " + where + ")"
 	return sqlQuery
 }
 
-func addOrderBy(sqlQuery string, tablePrefix string, mailSearch *MailSearch) string {
-	switch mailSearch.OrderByField {
-	case "subject":
-		sqlQuery += fmt.Sprintf(" ORDER BY %s.subject ", tablePrefix)
-
-	case "from":
-		sqlQuery += fmt.Sprintf(" ORDER BY %s.fromAddress ", tablePrefix)
+func getDeleteHeadersQuery(startDate string) string {
+	where := ""
 
-	default:
-		sqlQuery += fmt.Sprintf(" ORDER BY %s.dateSent ", tablePrefix)
+	if len(startDate) > 0 {
+		where = where + " AND mailitem.dateSent <= ? "
 	}
 
-	switch mailSearch.OrderByDirection {
-	case "asc":
-		sqlQuery += " ASC "
+	sqlQuery := "DELETE FROM mailheader WHERE mailheader.mailItemId IN (SELECT mailitem.id FROM mailitem WHERE 1=1 " + where + ")"
+	return sqlQuery
+}
+
+func getDeleteMailQuery(startDate string) string {
+	where := ""
 
-	default:
-		sqlQuery += " DESC "
+	if len(startDate) > 0 {
+		where = where + " AND dateSent <= ? "
 	}
 
+	sqlQuery := "DELETE FROM mailitem WHERE 1=1" + where
 	return sqlQuery
 }
 
-func addQuery(db *pop.Connection, mailSearch *MailSearch) *pop.Connection {
-	if mailSearch == nil {
-		return db
+// getDeleteMailByIDQueries returns the queries deleting a single mail item, each taking the mail item ID. The mail item
+// itself is deleted last.
+func getDeleteMailByIDQueries() []string {
+	return []string{
+		"DELETE FROM attachment WHERE attachment.mailItemId = ?",
+		"DELETE FROM rawmessage WHERE rawmessage.id = ?",
+		"DELETE FROM messagepart WHERE messagepart.mailItemId = ?",
+		"DELETE FROM mailheader WHERE mailheader.mailItemId = ?",
+		"DELETE FROM mailitem WHERE mailitem.id = ?",
 	}
+}
 
-	if len(strings.TrimSpace(mailSearch.Message)) > 0 {
-		db.Where(
-			`(mailitem.body LIKE ? OR mailitem.subject LIKE ?)`,
-			"%"+mailSearch.Message+"%",
-			"%"+mailSearch.Message+"%",
-		)
-	}
+// addOrderBy orders a mail query by date sent, subject or sender, newest or last first unless the search asks for
+// ascending order. Mail is ordered by ID as well so that pages stay stable when the ordered values are equal.
+func addOrderBy(query *pop.Query, mailSearch *MailSearch) *pop.Query {
+	column := "mailitem.dateSent"
+	direction := "DESC"
 
-	if len(strings.TrimSpace(mailSearch.From)) > 0 {
-		db.Where(`mailitem.fromAddress LIKE ?`, "%"+mailSearch.From+"%")
-	}
+	if mailSearch != nil {
+		switch mailSearch.OrderByField {
+		case "subject":
+			column = "mailitem.subject"
 
-	if len(strings.TrimSpace(mailSearch.To)) > 0 {
-		db.Where(`mailitem.toAddressList LIKE ?`, "%"+mailSearch.To+"%")
-	}
+		case "from":
+			column = "mailitem.fromAddress"
+		}
 
-	if len(strings.TrimSpace(mailSearch.Start)) > 0 {
-		if date, err := time.Parse("2006-01-02", mailSearch.Start); err == nil {
-			db.Where(`mailitem.dateSent >= ?`, date)
+		if strings.EqualFold(mailSearch.OrderByDirection, "asc") {
+			direction = "ASC"
 		}
 	}
 
-	if len(strings.TrimSpace(mailSearch.End)) > 0 {
-		if date, err := time.Parse("2006-01-02", mailSearch.End); err == nil {
-			db.Where(`mailitem.dateSent < ?`, date.Add(time.Hour*24))
+	return query.Order(fmt.Sprintf("%s %s, mailitem.id %s", column, direction, direction))
+}
+
+// addCursor limits a mail query to the mail after the cursor of the search and orders it by received time, newest first
+// for Cursor and oldest first for Since. A Since search also leaves out the mail received from ReceivedBefore on.
+func addCursor(query *pop.Query, mailSearch *MailSearch) *pop.Query {
+	if mailSearch.Since != nil {
+		if !mailSearch.Since.IsZero() {
+			query.Where(
+				`(mailitem.created_at > ? OR (mailitem.created_at = ? AND mailitem.id > ?))`,
+				mailSearch.Since.ReceivedAt,
+				mailSearch.Since.ReceivedAt,
+				mailSearch.Since.ID,
+			)
 		}
+
+		if !mailSearch.ReceivedBefore.IsZero() {
+			query.Where("mailitem.created_at < ?", mailSearch.ReceivedBefore)
+		}
+
+		return query.Order("mailitem.created_at ASC, mailitem.id ASC")
 	}
 
-	return db
+	if !mailSearch.Cursor.IsZero() {
+		query.Where(
+			`(mailitem.created_at < ? OR (mailitem.created_at = ? AND mailitem.id < ?))`,
+			mailSearch.Cursor.ReceivedAt,
+			mailSearch.Cursor.ReceivedAt,
+			mailSearch.Cursor.ID,
+		)
+	}
+
+	return query.Order("mailitem.created_at DESC, mailitem.id DESC")
 }
 
-func addSearchCriteria(sqlQuery string, parameters []interface{}, mailSearch *MailSearch) (string, []interface{}) {
-	var date time.Time
-	var err error
+func addQuery(db *pop.Connection, mailSearch *MailSearch) *pop.Query {
+	query := pop.Q(db)
+
+	if mailSearch == nil {
+		return query
+	}
 
 	if len(strings.TrimSpace(mailSearch.Message)) > 0 {
-		sqlQuery += `
-			AND (
-				mailitem.body LIKE ?
-				OR mailitem.subject LIKE ?
-			)
-		`
+		query.Where(
+			`(mailitem.body LIKE ? OR mailitem.subject LIKE ?)`,
+			"%"+mailSearch.Message+"%",
+			"%"+mailSearch.Message+"%",
+		)
+	}
 
-		parameters = append(parameters, "%"+mailSearch.Message+"%")
-		parameters = append(parameters, "%"+mailSearch.Message+"%")
+	if len(strings.TrimSpace(mailSearch.Subject)) > 0 {
+		query.Where(`mailitem.subject LIKE ?`, "%"+mailSearch.Subject+"%")
 	}
 
 	if len(strings.TrimSpace(mailSearch.From)) > 0 {
-		sqlQuery += `
-			AND mailitem.fromAddress LIKE ?
-		`
-
-		parameters = append(parameters, "%"+mailSearch.From+"%")
+		query.Where(
+			`(mailitem.fromAddress LIKE ? OR mailitem.fromAddressNormalized LIKE ?)`,
+			"%"+mailSearch.From+"%",
+			"%"+model.NormalizeAddress(mailSearch.From)+"%",
+		)
 	}
 
 	if len(strings.TrimSpace(mailSearch.To)) > 0 {
-		sqlQuery += `
-			AND mailitem.toAddressList LIKE ?
-		`
-
-		parameters = append(parameters, "%"+mailSearch.To+"%")
+		query.Where(
+			`(mailitem.toAddresses LIKE ? OR mailitem.toAddressesNormalized LIKE ?)`,
+			"%"+mailSearch.To+"%",
+			"%"+model.NormalizeAddress(mailSearch.To)+"%",
+		)
 	}
 
 	if len(strings.TrimSpace(mailSearch.Start)) > 0 {
-		if date, err = time.Parse("2006-01-02", mailSearch.Start); err == nil {
-			sqlQuery += `
-				AND mailitem.dateSent >= ?
-			`
-
-			parameters = append(parameters, date)
+		if date, err := time.Parse("2006-01-02", mailSearch.Start); err == nil {
+			query.Where(`mailitem.dateSent >= ?`, date)
 		}
 	}
 
 	if len(strings.TrimSpace(mailSearch.End)) > 0 {
-		if date, err = time.Parse("2006-01-02", mailSearch.End); err == nil {
-			datePlusOne := date.Add(time.Hour * 24)
-
-			sqlQuery += `
-				AND mailitem.dateSent < ?
-			`
-
-			parameters = append(parameters, datePlusOne)
+		if date, err := time.Parse("2006-01-02", mailSearch.End); err == nil {
+			query.Where(`mailitem.dateSent < ?`, date.Add(time.Hour*24))
 		}
 	}
 
-	return sqlQuery, parameters
-}
-
-func storeAttachments(mailItemID string, transaction *sql.Tx, attachments []*model.Attachment) error {
-	var err error
-	var attachmentID uuid.UUID
-
-	for _, currentAttachment := range attachments {
-		if attachmentID, err = uuid.NewV4(); err != nil {
-			return fmt.Errorf("Error generating ID for attachment: %s", err.Error())
-		}
-
-		statement, err := transaction.Prepare(getInsertAttachmentQuery())
-		if err != nil {
-			return fmt.Errorf("Error preparing insert attachment statement: %s", err.Error())
-		}
-
-		_, err = statement.Exec(
-			attachmentID,
-			mailItemID,
-			currentAttachment.Headers.FileName,
-			currentAttachment.Headers.ContentType,
-			currentAttachment.Contents,
-		)
+	for _, header := range mailSearch.Headers {
+		sqlQuery, parameters := getHeaderSearchQuery(header)
+		query.Where(sqlQuery, parameters...)
+	}
 
-		if err != nil {
-			return fmt.Errorf("Error executing insert attachment in StoreMail: %s", err.Error())
-		}
+	return query
+}
 
-		statement.Close()
-		currentAttachment.ID = attachmentID
+// getHeaderSearchQuery returns a condition matching mail items that carry the header. Header names are matched without
+// regard to case and values exactly. An empty value matches any value.
+func getHeaderSearchQuery(header HeaderSearch) (string, []interface{}) {
+	if header.Value == "" {
+		return `mailitem.id IN (SELECT mailheader.mailItemId FROM mailheader WHERE LOWER(mailheader.name) = LOWER(?))`,
+			[]interface{}{header.Name}
 	}
 
-	return nil
+	return `mailitem.id IN (SELECT mailheader.mailItemId FROM mailheader WHERE LOWER(mailheader.name) = LOWER(?) AND mailheader.value = ?)`,
+		[]interface{}{header.Name, header.Value}
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/adampresley/webframework/sanitizer"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
+	_ "github.com/mattn/go-sqlite3" // the sqlite dialect in pop needs the driver registered
 
 	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
@@ -100,11 +101,12 @@ func (s *ORM) MigrateDown(steps int) error {
 	return migrationBox.Down(steps)
 }
 
-// GetAttachment retrieves an attachment for a given mail item.
-func (s *ORM) GetAttachment(attachmentID uuid.UUID) (*model.Attachment, error) {
+// GetAttachment retrieves an attachment for a given mail item. This returns nil if the mail item has no such
+// attachment.
+func (s *ORM) GetAttachment(mailID, attachmentID uuid.UUID) (*model.Attachment, error) {
 	attachment := model.Attachment{}
 
-	err := s.db.Find(&attachment, attachmentID)
+	err := s.db.Where("mailItemId = ? AND id = ?", mailID, attachmentID).First(&attachment)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -123,6 +125,7 @@ func (s *ORM) GetMailByID(id uuid.UUID) (*model.MailItem, error) {
 
 	eagerPreloadFields := []string{
 		"Attachments",
+		"Headers",
 	}
 
 	err := s.db.EagerPreload(eagerPreloadFields...).Find(&item, id)
@@ -139,39 +142,123 @@ func (s *ORM) GetMailByID(id uuid.UUID) (*model.MailItem, error) {
 	return &item, nil
 }
 
-// GetMailMessageRawByID retrieves a single mail item and attachment by ID.
-func (s *ORM) GetMailMessageRawByID(id uuid.UUID) (string, error) {
-	item := model.MailItem{}
+// GetMailMessageRawByID retrieves the message of a mail item exactly as it was received. This returns nil if the
+// mail item has no raw message, such as one stored before raw messages were kept.
+func (s *ORM) GetMailMessageRawByID(id uuid.UUID) ([]byte, error) {
+	raw := model.RawMessage{}
 
-	err := s.db.Find(&item, id)
+	err := s.db.Find(&raw, id)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		return "", nil
+		return nil, nil
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("failed to get body: %w", err)
+		return nil, fmt.Errorf("failed to get raw message: %w", err)
 	}
 
-	return item.Body, nil
+	return raw.Content, nil
 }
 
-// GetMailCollection retrieves a slice of mail items starting at offset and getting length number of records.
-func (s *ORM) GetMailCollection(offset, length int, mailSearch *MailSearch) ([]model.MailItem, error) {
-	items := []model.MailItem{}
+// GetMessagePartTree retrieves the MIME part tree of a mail item. Part contents are left out; use GetMessagePart to
+// get them. This returns nil if the mail item has no stored parts.
+func (s *ORM) GetMessagePartTree(mailID uuid.UUID) (*model.MessagePart, error) {
+	parts := []model.MessagePart{}
+
+	err := s.db.
+		Select(messagePartSummaryColumns...).
+		Where("mailItemId = ?", mailID).
+		All(&parts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message parts: %w", err)
+	}
 
-	err := addQuery(s.db, mailSearch).
-		Paginate(offset, length).
-		EagerPreload().All(&items)
+	return model.BuildMessagePartTree(parts), nil
+}
+
+// GetMessagePart retrieves a single part of a mail item, including its content. Child parts are not loaded.
+func (s *ORM) GetMessagePart(mailID, partID uuid.UUID) (*model.MessagePart, error) {
+	part := model.MessagePart{}
 
+	err := s.db.Where("mailItemId = ? AND id = ?", mailID, partID).First(&part)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
 
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message part: %w", err)
+	}
+
+	return &part, nil
+}
+
+// GetMailCollection retrieves a slice of mail items starting at offset and getting length number of records. The
+// offset is expected to fall on a page boundary, and is ignored when the search holds a cursor. Attachments are loaded
+// without their contents.
+func (s *ORM) GetMailCollection(offset, length int, mailSearch *MailSearch) ([]model.MailItem, error) {
+	items := []model.MailItem{}
+
+	if length <= 0 {
+		return items, nil
+	}
+
+	query := addQuery(s.db, mailSearch)
+
+	if mailSearch != nil && (mailSearch.Cursor != nil || mailSearch.Since != nil) {
+		query = addCursor(query, mailSearch).Limit(length)
+	} else {
+		query = addOrderBy(query, mailSearch).Paginate(offset/length+1, length)
+	}
+
+	err := query.All(&items)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get mail items: %w", err)
 	}
 
-	return nil, errors.New("unimplemented")
+	if err = s.loadAttachmentSummaries(items); err != nil {
+		return nil, err
+	}
+
+	for index := range items {
+		items[index].Sanitize(s.sanitizer)
+	}
+
+	return items, nil
+}
+
+// loadAttachmentSummaries fills in the attachments of each mail item, leaving out the attachment contents.
+func (s *ORM) loadAttachmentSummaries(items []model.MailItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]any, 0, len(items))
+	byID := make(map[uuid.UUID]*model.MailItem, len(items))
+
+	for index := range items {
+		items[index].Attachments = make([]model.Attachment, 0)
+
+		ids = append(ids, items[index].ID)
+		byID[items[index].ID] = &items[index]
+	}
+
+	attachments := []model.Attachment{}
+
+	err := s.db.
+		Select(attachmentSummaryColumns...).
+		Where("mailItemId IN (?)", ids...).
+		Order("created_at ASC").
+		All(&attachments)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	for _, attachment := range attachments {
+		if item, ok := byID[attachment.MailItemID]; ok {
+			item.Attachments = append(item.Attachments, attachment)
+		}
+	}
+
+	return nil
 }
 
 // GetMailCount returns the number of total records in the mail items table.
@@ -191,24 +278,93 @@ func (s *ORM) DeleteMailsAfterDate(startDate string) (int64, error) {
 		return 0, fmt.Errorf("%w: Error deleting attachments for mails after %s", err, startDate)
 	}
 
-	if err := s.db.RawQuery(getDeleteMailQuery(startDate), parameters...).Exec(); err != nil {
+	if err := s.db.RawQuery(getDeleteRawMessagesQuery(startDate), parameters...).Exec(); err != nil {
+		return 0, fmt.Errorf("%w: Error deleting raw messages for mails after %s", err, startDate)
+	}
+
+	if err := s.db.RawQuery(getDeleteMessagePartsQuery(startDate), parameters...).Exec(); err != nil {
+		return 0, fmt.Errorf("%w: Error deleting message parts for mails after %s", err, startDate)
+	}
+
+	if err := s.db.RawQuery(getDeleteHeadersQuery(startDate), parameters...).Exec(); err != nil {
+		return 0, fmt.Errorf("%w: Error deleting headers for mails after %s", err, startDate)
+	}
+
+	count, err := s.db.RawQuery(getDeleteMailQuery(startDate), parameters...).ExecWithCount()
+	if err != nil {
 		return 0, fmt.Errorf("%w: Error deleting mails after %s", err, startDate)
 	}
 
-	return 0, nil // TODO: count number of mail items deleted
+	return int64(count), nil
+}
+
+// DeleteMailByID deletes a single mail item along with its raw message, part tree, headers and attachments.
+func (s *ORM) DeleteMailByID(mailID uuid.UUID) error {
+	return s.db.Transaction(func(tx *pop.Connection) error {
+		for _, query := range getDeleteMailByIDQueries() {
+			if err := tx.RawQuery(query, mailID).Exec(); err != nil {
+				return fmt.Errorf("%w: Error deleting mail %s", err, mailID)
+			}
+		}
+
+		return nil
+	})
 }
 
-// StoreMail writes a mail item and its attachments to the storage device. This returns the new mail ID.
+// StoreMail writes a mail item, its raw message, headers, part tree and attachments to the storage device.
 func (s *ORM) StoreMail(mailItem *model.MailItem) error {
-	vErr, err := s.db.ValidateAndCreate(&mailItem)
+	err := s.db.Transaction(func(tx *pop.Connection) error {
+		// the received time orders mail for cursors, so it is taken as late as possible to keep it close to commit order
+		mailItem.CreatedAt = time.Now()
+		mailItem.UpdatedAt = mailItem.CreatedAt
+
+		vErr, err := tx.ValidateAndCreate(mailItem)
+		if err != nil {
+			return err
+		}
+
+		if vErr != nil && vErr.HasAny() {
+			return fmt.Errorf("primary email object validation failed: %w", vErr)
+		}
+
+		if mailItem.Raw != nil {
+			if err = tx.Create(model.NewRawMessage(mailItem)); err != nil {
+				return fmt.Errorf("failed to store raw message: %w", err)
+			}
+		}
+
+		for _, attachment := range append(mailItem.Attachments, mailItem.InlineAttachments...) {
+			attachment.MailItemID = mailItem.ID
+
+			if err = tx.Create(&attachment); err != nil {
+				return fmt.Errorf("failed to store attachment: %w", err)
+			}
+		}
+
+		for index := range mailItem.Headers {
+			mailItem.Headers[index].MailItemID = mailItem.ID
+
+			if err = tx.Create(&mailItem.Headers[index]); err != nil {
+				return fmt.Errorf("failed to store header: %w", err)
+			}
+		}
+
+		if mailItem.PartTree != nil {
+			for _, part := range mailItem.PartTree.Flatten() {
+				part.MailItemID = mailItem.ID
+
+				if err = tx.Create(part); err != nil {
+					return fmt.Errorf("failed to store message part: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if vErr != nil && vErr.HasAny() {
-		return fmt.Errorf("primary email object validation failed: %w", vErr)
-	}
-
 	s.logger.Info("New mail item written to database.")
 
 	return nil
@@ -0,0 +1,481 @@
+package persistence_test
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/adampresley/webframework/sanitizer"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/persistence"
+)
+
+func TestORM_StoreMail(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	item.FromAddress = "bounces@example.com"
+	item.ToAddresses = model.MailAddressCollection{"to@example.com", "hidden@example.com"}
+	item.HeaderFrom = model.MailAddressCollection{"newsletter@example.com"}
+	item.HeaderSender = "sender@example.com"
+	item.HeaderReplyTo = model.MailAddressCollection{"replies@example.com"}
+	item.HeaderTo = model.MailAddressCollection{"to@example.com"}
+	item.BccAddresses = model.MailAddressCollection{"hidden@example.com"}
+	item.Subject = "Stored"
+	item.TextBody = "Café"
+	item.HTMLBody = "<p>Café</p>"
+	item.Body = item.HTMLBody
+
+	require.NoError(t, orm.StoreMail(item))
+
+	stored, err := orm.GetMailByID(item.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Empty(t, stored.Headers)
+
+	assert.Equal(t, item.FromAddress, stored.FromAddress)
+	assert.Equal(t, item.ToAddresses, stored.ToAddresses)
+	assert.Equal(t, item.HeaderFrom, stored.HeaderFrom)
+	assert.Equal(t, item.HeaderSender, stored.HeaderSender)
+	assert.Equal(t, item.HeaderReplyTo, stored.HeaderReplyTo)
+	assert.Equal(t, item.HeaderTo, stored.HeaderTo)
+	assert.Empty(t, stored.HeaderCc)
+	assert.Equal(t, item.BccAddresses, stored.BccAddresses)
+	assert.Equal(t, item.TextBody, stored.TextBody)
+	assert.Equal(t, item.HTMLBody, stored.HTMLBody)
+
+	count, err := orm.GetMailCount(&persistence.MailSearch{To: "hidden@"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestORM_GetMailMessageRawByID(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	raw := []byte("Subject: Raw\r\nContent-Type: text/plain\r\n\r\n.leading dot\r\n\xff not utf-8\r\n")
+
+	item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	item.FromAddress = "from@example.com"
+	item.Subject = "Raw"
+	item.Raw = raw
+
+	require.NoError(t, orm.StoreMail(item))
+
+	stored, err := orm.GetMailMessageRawByID(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, raw, stored)
+
+	// mail stored without a raw message has nothing to return
+	withoutRaw := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	withoutRaw.FromAddress = "from@example.com"
+	require.NoError(t, orm.StoreMail(withoutRaw))
+
+	stored, err = orm.GetMailMessageRawByID(withoutRaw.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = orm.DeleteMailsAfterDate("")
+	require.NoError(t, err)
+
+	stored, err = orm.GetMailMessageRawByID(item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestORM_GetAttachment(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	item.FromAddress = "from@example.com"
+
+	inline := model.NewAttachment(&model.AttachmentHeader{ContentType: "image/png; name=logo.png"}, "iVBORw0KGgo=", nil)
+	inline.ContentID = "logo@example.com"
+	inline.Inline = true
+	item.InlineAttachments = append(item.InlineAttachments, *inline)
+
+	attached := model.NewAttachment(&model.AttachmentHeader{FileName: "a.pdf", ContentType: "application/pdf"}, "%PDF", nil)
+	item.Attachments = append(item.Attachments, *attached)
+
+	require.NoError(t, orm.StoreMail(item))
+
+	stored, err := orm.GetMailByID(item.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Attachments, 2)
+
+	attachment, err := orm.GetAttachment(item.ID, inline.ID)
+	require.NoError(t, err)
+	require.NotNil(t, attachment)
+	assert.Equal(t, "logo.png", attachment.FileName)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, "logo@example.com", attachment.ContentID)
+	assert.True(t, attachment.Inline)
+	assert.Equal(t, "iVBORw0KGgo=", attachment.Contents)
+
+	// attachments are only found through the mail item they belong to
+	other := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	other.FromAddress = "from@example.com"
+	require.NoError(t, orm.StoreMail(other))
+
+	attachment, err = orm.GetAttachment(other.ID, attached.ID)
+	require.NoError(t, err)
+	assert.Nil(t, attachment)
+}
+
+func TestORM_DeleteMailByID(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	item.FromAddress = "from@example.com"
+	item.Raw = []byte("Subject: Deleted\r\n\r\nbody\r\n")
+	item.Headers = model.NewMailHeaders(item.ID, "Subject: Deleted\r\n\r\n")
+
+	attached := model.NewAttachment(&model.AttachmentHeader{FileName: "a.pdf", ContentType: "application/pdf"}, "%PDF", nil)
+	item.Attachments = append(item.Attachments, *attached)
+
+	require.NoError(t, orm.StoreMail(item))
+
+	kept := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	kept.FromAddress = "from@example.com"
+	require.NoError(t, orm.StoreMail(kept))
+
+	require.NoError(t, orm.DeleteMailByID(item.ID))
+
+	stored, err := orm.GetMailByID(item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	raw, err := orm.GetMailMessageRawByID(item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+
+	attachment, err := orm.GetAttachment(item.ID, attached.ID)
+	require.NoError(t, err)
+	assert.Nil(t, attachment)
+
+	stored, err = orm.GetMailByID(kept.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+
+	// pruning counts the mail items it deletes
+	count, err := orm.DeleteMailsAfterDate("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestORM_GetMessagePartTree(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+	item.FromAddress = "from@example.com"
+
+	require.NoError(t, item.Message.BuildMessages("Content-Type: multipart/mixed; boundary=b\r\n\r\n"+
+		"--b\r\nContent-Type: text/plain\r\n\r\nhello\r\n"+
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\n\r\n%PDF\r\n"+
+		"--b--\r\n"))
+
+	item.PartTree = model.NewMessagePartTree(item.ID, item.Message)
+
+	require.NoError(t, orm.StoreMail(item))
+
+	tree, err := orm.GetMessagePartTree(item.ID)
+	require.NoError(t, err)
+	require.NotNil(t, tree)
+
+	assert.Equal(t, "multipart/mixed", tree.ContentType)
+	assert.Nil(t, tree.Content, "contents are not loaded with the tree")
+	require.Len(t, tree.Parts, 2)
+	assert.Equal(t, "text/plain", tree.Parts[0].ContentType)
+	assert.Equal(t, "a.pdf", tree.Parts[1].FileName)
+	assert.Equal(t, 4, tree.Parts[1].Size)
+
+	part, err := orm.GetMessagePart(item.ID, tree.Parts[1].ID)
+	require.NoError(t, err)
+	require.NotNil(t, part)
+	assert.Equal(t, []byte("%PDF"), part.Content)
+
+	// parts are only found through their own mail item
+	part, err = orm.GetMessagePart(uuid.Must(uuid.NewV4()), tree.Parts[1].ID)
+	require.NoError(t, err)
+	assert.Nil(t, part)
+
+	_, err = orm.DeleteMailsAfterDate("")
+	require.NoError(t, err)
+
+	tree, err = orm.GetMessagePartTree(item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, tree)
+}
+
+func TestORM_SearchHeaders(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	var item *model.MailItem
+
+	for _, correlationID := range []string{"abc", "abcd"} {
+		item = model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+		item.FromAddress = "from@example.com"
+		item.Headers = model.NewMailHeaders(item.ID, "X-Correlation-ID: "+correlationID+"\r\nSubject: Test\r\n\r\n")
+
+		require.NoError(t, orm.StoreMail(item))
+	}
+
+	stored, err := orm.GetMailByID(item.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Headers, 2)
+	assert.Equal(t, "X-Correlation-ID", stored.Headers[0].Name)
+	assert.Equal(t, "abcd", stored.Headers[0].Value)
+	assert.Equal(t, "Subject", stored.Headers[1].Name)
+
+	tests := []struct {
+		headers  []persistence.HeaderSearch
+		expected int
+	}{
+		{headers: []persistence.HeaderSearch{{Name: "x-correlation-id", Value: "abc"}}, expected: 1},
+		{headers: []persistence.HeaderSearch{{Name: "X-Correlation-ID"}}, expected: 2},
+		{headers: []persistence.HeaderSearch{{Name: "X-Correlation-ID", Value: "abc"}, {Name: "Subject", Value: "Other"}}, expected: 0},
+		{headers: []persistence.HeaderSearch{{Name: "Message-ID"}}, expected: 0},
+	}
+
+	for _, test := range tests {
+		count, err := orm.GetMailCount(&persistence.MailSearch{Headers: test.headers})
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, count, test.headers)
+	}
+}
+
+func TestORM_GetMailCollection(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	mails := []struct {
+		from     string
+		to       string
+		subject  string
+		dateSent string
+	}{
+		{from: "carol@example.com", to: "one@example.com", subject: "Bravo", dateSent: "2026-01-01 10:00:00"},
+		{from: "alice@example.com", to: "two@example.com", subject: "Delta", dateSent: "2026-01-03 10:00:00"},
+		{from: "bob@example.com", to: "one@example.com", subject: "Alpha", dateSent: "2026-01-02 10:00:00"},
+		{from: "dave@example.com", to: "one@example.com", subject: "Charlie", dateSent: "2026-01-04 10:00:00"},
+	}
+
+	for _, mail := range mails {
+		item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+		item.FromAddress = mail.from
+		item.ToAddresses = model.MailAddressCollection{mail.to}
+		item.Subject = mail.subject
+		item.DateSent = mail.dateSent
+
+		if mail.subject == "Alpha" {
+			attachment := model.NewAttachment(&model.AttachmentHeader{FileName: "a.pdf", ContentType: "application/pdf"}, "%PDF", nil)
+			item.Attachments = append(item.Attachments, *attachment)
+		}
+
+		require.NoError(t, orm.StoreMail(item))
+	}
+
+	subjects := func(items []model.MailItem) []string {
+		result := make([]string, 0, len(items))
+		for _, item := range items {
+			result = append(result, item.Subject)
+		}
+
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		offset   int
+		length   int
+		search   *persistence.MailSearch
+		expected []string
+	}{
+		{
+			name:     "newest first by default",
+			length:   10,
+			search:   &persistence.MailSearch{},
+			expected: []string{"Charlie", "Delta", "Alpha", "Bravo"},
+		},
+		{
+			name:     "by subject ascending",
+			length:   10,
+			search:   &persistence.MailSearch{OrderByField: "subject", OrderByDirection: "asc"},
+			expected: []string{"Alpha", "Bravo", "Charlie", "Delta"},
+		},
+		{
+			name:     "by sender descending",
+			length:   10,
+			search:   &persistence.MailSearch{OrderByField: "from", OrderByDirection: "desc"},
+			expected: []string{"Charlie", "Bravo", "Alpha", "Delta"},
+		},
+		{
+			name:     "second page",
+			offset:   2,
+			length:   2,
+			search:   &persistence.MailSearch{OrderByField: "subject", OrderByDirection: "asc"},
+			expected: []string{"Charlie", "Delta"},
+		},
+		{
+			name:     "filtered by recipient",
+			length:   10,
+			search:   &persistence.MailSearch{To: "one@example.com", OrderByDirection: "asc"},
+			expected: []string{"Bravo", "Alpha", "Charlie"},
+		},
+		{
+			name:     "filtered by date",
+			length:   10,
+			search:   &persistence.MailSearch{Start: "2026-01-02", End: "2026-01-03"},
+			expected: []string{"Delta", "Alpha"},
+		},
+	}
+
+	for _, test := range tests {
+		items, err := orm.GetMailCollection(test.offset, test.length, test.search)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, subjects(items), test.name)
+	}
+
+	// attachments are listed without their contents
+	items, err := orm.GetMailCollection(0, 10, &persistence.MailSearch{Message: "Alpha"})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Len(t, items[0].Attachments, 1)
+	assert.Equal(t, "a.pdf", items[0].Attachments[0].FileName)
+	assert.Equal(t, "application/pdf", items[0].Attachments[0].ContentType)
+	assert.Empty(t, items[0].Attachments[0].Contents)
+
+	count, err := orm.GetMailCount(&persistence.MailSearch{To: "one@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestORM_GetMailCollection_Cursor(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	store := func(subject string) {
+		item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+		item.FromAddress = "from@example.com"
+		item.Subject = subject
+
+		require.NoError(t, orm.StoreMail(item))
+	}
+
+	subjects := func(items []model.MailItem) []string {
+		result := make([]string, 0, len(items))
+		for _, item := range items {
+			result = append(result, item.Subject)
+		}
+
+		return result
+	}
+
+	for _, subject := range []string{"one", "two", "three", "four", "five"} {
+		store(subject)
+	}
+
+	// paging from the newest mail is not thrown off by mail arriving in between
+	items, err := orm.GetMailCollection(0, 2, &persistence.MailSearch{Cursor: &persistence.MailCursor{}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"five", "four"}, subjects(items))
+
+	store("six")
+
+	items, err = orm.GetMailCollection(0, 2, &persistence.MailSearch{Cursor: persistence.NewMailCursor(&items[1])})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"three", "two"}, subjects(items))
+
+	items, err = orm.GetMailCollection(0, 2, &persistence.MailSearch{Cursor: persistence.NewMailCursor(&items[1])})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one"}, subjects(items))
+
+	// since returns mail received after the cursor, oldest first
+	items, err = orm.GetMailCollection(0, 10, &persistence.MailSearch{Since: &persistence.MailCursor{}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "three", "four", "five", "six"}, subjects(items))
+
+	// mail received from ReceivedBefore on is left for a later poll
+	settled, err := orm.GetMailCollection(0, 10, &persistence.MailSearch{
+		Since:          &persistence.MailCursor{},
+		ReceivedBefore: items[5].CreatedAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "three", "four", "five"}, subjects(settled))
+
+	since := persistence.NewMailCursor(&items[3])
+
+	// a cursor survives being passed through a client
+	since, err = persistence.ParseMailCursor(since.String())
+	require.NoError(t, err)
+
+	items, err = orm.GetMailCollection(0, 10, &persistence.MailSearch{Since: since})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"five", "six"}, subjects(items))
+
+	items, err = orm.GetMailCollection(0, 10, &persistence.MailSearch{Since: persistence.NewMailCursor(&items[1])})
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestORM_StoreMail_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	orm := newTestORM(t)
+
+	var wg sync.WaitGroup
+
+	for _, subject := range []string{"one", "two"} {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			item := model.NewEmptyMailItem(slog.New(slog.DiscardHandler))
+			item.FromAddress = "from@example.com"
+			item.Subject = subject
+
+			assert.NoError(t, orm.StoreMail(item))
+		}()
+	}
+
+	wg.Wait()
+
+	items, err := orm.GetMailCollection(0, 10, &persistence.MailSearch{Since: &persistence.MailCursor{}})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.ElementsMatch(t, []string{"one", "two"}, []string{items[0].Subject, items[1].Subject})
+	assert.NotEqual(t, items[0].ID, items[1].ID)
+}
+
+func newTestORM(t *testing.T) *persistence.ORM {
+	t.Helper()
+
+	orm, err := persistence.NewORM(persistence.Config{
+		Dialect:  "sqlite",
+		Database: filepath.Join(t.TempDir(), "mailslurper.db"),
+	}, sanitizer.NewXSSService(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	require.NoError(t, orm.MigrateUp())
+
+	return orm
+}
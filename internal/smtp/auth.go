@@ -0,0 +1,82 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+)
+
+// Authentication mechanisms supported by the AUTH command.
+const (
+	AUTH_MECHANISM_PLAIN    string = "PLAIN"
+	AUTH_MECHANISM_LOGIN    string = "LOGIN"
+	AUTH_MECHANISM_CRAM_MD5 string = "CRAM-MD5"
+)
+
+// AuthMechanisms is the list of mechanisms advertised in response to EHLO.
+var AuthMechanisms = []string{AUTH_MECHANISM_PLAIN, AUTH_MECHANISM_LOGIN, AUTH_MECHANISM_CRAM_MD5}
+
+// CredentialValidator checks credentials presented by an SMTP client during AUTH.
+type CredentialValidator interface {
+	// ValidatePassword is used by the PLAIN and LOGIN mechanisms.
+	ValidatePassword(userName, password string) bool
+	// ValidateCRAMMD5 checks a hex encoded HMAC-MD5 digest of the challenge sent to the client.
+	ValidateCRAMMD5(userName, challenge, digest string) bool
+}
+
+// NewCredentialValidator returns the validator for the configured authentication mode, or nil if AUTH is disabled.
+func NewCredentialValidator(config slurperio.SMTPAuthConfig) CredentialValidator {
+	switch config.Mode {
+	case slurperio.SMTPAuthAny:
+		return AnyCredentialValidator{}
+	case slurperio.SMTPAuthCredentials:
+		return CredentialMapValidator(config.Credentials)
+	default:
+		return nil
+	}
+}
+
+// AnyCredentialValidator accepts every user name and password.
+type AnyCredentialValidator struct{}
+
+// ValidatePassword always returns true.
+func (AnyCredentialValidator) ValidatePassword(_, _ string) bool {
+	return true
+}
+
+// ValidateCRAMMD5 always returns true.
+func (AnyCredentialValidator) ValidateCRAMMD5(_, _, _ string) bool {
+	return true
+}
+
+// CredentialMapValidator checks credentials against a map of user names to plain text passwords.
+type CredentialMapValidator map[string]string
+
+// ValidatePassword returns true if the user exists and the password matches.
+func (v CredentialMapValidator) ValidatePassword(userName, password string) bool {
+	stored, ok := v[userName]
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// ValidateCRAMMD5 returns true if the user exists and the digest was computed with the user's password.
+func (v CredentialMapValidator) ValidateCRAMMD5(userName, challenge, digest string) bool {
+	stored, ok := v[userName]
+	if !ok {
+		return false
+	}
+
+	mac := hmac.New(md5.New, []byte(stored))
+	mac.Write([]byte(challenge))
+
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(digest))
+}
@@ -0,0 +1,226 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+// AuthCommandExecutor process the command AUTH. The PLAIN, LOGIN and CRAM-MD5 mechanisms are supported.
+type AuthCommandExecutor struct {
+	logger    *slog.Logger
+	reader    *Reader
+	writer    *Writer
+	session   *Session
+	validator CredentialValidator
+}
+
+// NewAuthCommandExecutor creates a new struct. A nil validator means the AUTH extension is disabled.
+func NewAuthCommandExecutor(
+	logger *slog.Logger,
+	reader *Reader,
+	writer *Writer,
+	session *Session,
+	validator CredentialValidator,
+) *AuthCommandExecutor {
+	return &AuthCommandExecutor{
+		logger:    logger,
+		reader:    reader,
+		writer:    writer,
+		session:   session,
+		validator: validator,
+	}
+}
+
+// Process handles the AUTH command. The command takes the form "AUTH <mechanism> [initial-response]". Challenges are
+// sent to the client with a 334 reply and each response is read from the connection until the exchange is complete.
+// On success the user name is recorded on the session so it can be attached to every mail item sent afterwards.
+func (e *AuthCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
+	var err error
+
+	if err = IsValidCommand(streamInput, "AUTH"); err != nil {
+		return err
+	}
+
+	if e.validator == nil {
+		return CommandNotImplemented()
+	}
+
+	if e.session.IsAuthenticated() {
+		return e.writer.SendResponse(SMTP_AUTH_ALREADY_MESSAGE)
+	}
+
+	split := strings.Fields(streamInput)
+	if len(split) < 2 {
+		return InvalidCommandFormat("AUTH")
+	}
+
+	initialResponse := ""
+	if len(split) > 2 {
+		initialResponse = split[2]
+	}
+
+	var (
+		userName string
+		valid    bool
+	)
+
+	switch strings.ToUpper(split[1]) {
+	case AUTH_MECHANISM_PLAIN:
+		userName, valid, err = e.authPlain(initialResponse)
+	case AUTH_MECHANISM_LOGIN:
+		userName, valid, err = e.authLogin(initialResponse)
+	case AUTH_MECHANISM_CRAM_MD5:
+		userName, valid, err = e.authCRAMMD5()
+	default:
+		return e.writer.SendResponse(SMTP_AUTH_MECHANISM_MESSAGE)
+	}
+
+	switch {
+	case errors.Is(err, errAuthCancelled):
+		return e.writer.SendResponse(SMTP_AUTH_CANCELLED_MESSAGE)
+	case errors.Is(err, errAuthMalformed):
+		return e.writer.SendResponse(SMTP_AUTH_MALFORMED_MESSAGE)
+	case err != nil:
+		return err
+	}
+
+	if !valid {
+		e.logger.Debug("Authentication failed", "mechanism", split[1], "user", userName)
+
+		return e.writer.SendResponse(SMTP_AUTH_FAILED_MESSAGE)
+	}
+
+	e.logger.Debug("Authentication succeeded", "mechanism", split[1], "user", userName)
+	e.session.AuthenticatedUser = userName
+
+	return e.writer.SendResponse(SMTP_AUTH_SUCCESS_MESSAGE)
+}
+
+// authPlain handles the PLAIN mechanism (RFC 4616). The response is "authzid\x00authcid\x00password".
+func (e *AuthCommandExecutor) authPlain(initialResponse string) (string, bool, error) {
+	var err error
+
+	response := initialResponse
+	if response == "" {
+		if response, err = e.challenge(""); err != nil {
+			return "", false, err
+		}
+	}
+
+	decoded, err := decodeAuthResponse(response)
+	if err != nil {
+		return "", false, err
+	}
+
+	parts := bytes.Split(decoded, []byte{0})
+	if len(parts) != 3 {
+		return "", false, errAuthMalformed
+	}
+
+	userName := string(parts[1])
+
+	return userName, e.validator.ValidatePassword(userName, string(parts[2])), nil
+}
+
+// authLogin handles the LOGIN mechanism, which prompts for the user name and password separately.
+func (e *AuthCommandExecutor) authLogin(initialResponse string) (string, bool, error) {
+	var (
+		response string
+		decoded  []byte
+		err      error
+	)
+
+	response = initialResponse
+	if response == "" {
+		if response, err = e.challenge("Username:"); err != nil {
+			return "", false, err
+		}
+	}
+
+	if decoded, err = decodeAuthResponse(response); err != nil {
+		return "", false, err
+	}
+
+	userName := string(decoded)
+
+	if response, err = e.challenge("Password:"); err != nil {
+		return "", false, err
+	}
+
+	if decoded, err = decodeAuthResponse(response); err != nil {
+		return "", false, err
+	}
+
+	return userName, e.validator.ValidatePassword(userName, string(decoded)), nil
+}
+
+// authCRAMMD5 handles the CRAM-MD5 mechanism (RFC 2195). The client answers a unique challenge with its user name and
+// an HMAC-MD5 digest of the challenge keyed by its password.
+func (e *AuthCommandExecutor) authCRAMMD5() (string, bool, error) {
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+
+	challenge := fmt.Sprintf("<%s.%d@mailslurper>", hex.EncodeToString(nonce), time.Now().Unix())
+
+	response, err := e.challenge(challenge)
+	if err != nil {
+		return "", false, err
+	}
+
+	decoded, err := decodeAuthResponse(response)
+	if err != nil {
+		return "", false, err
+	}
+
+	split := strings.Fields(string(decoded))
+	if len(split) != 2 {
+		return "", false, errAuthMalformed
+	}
+
+	return split[0], e.validator.ValidateCRAMMD5(split[0], challenge, strings.ToLower(split[1])), nil
+}
+
+// challenge sends a 334 reply with the base64 encoded prompt and returns the client's response.
+func (e *AuthCommandExecutor) challenge(prompt string) (string, error) {
+	if err := e.writer.SendResponse(SMTP_AUTH_CHALLENGE_PREFIX + base64.StdEncoding.EncodeToString([]byte(prompt))); err != nil {
+		return "", err
+	}
+
+	response, err := e.reader.Read()
+	if err != nil {
+		return "", fmt.Errorf("Error reading authentication response: %w", err)
+	}
+
+	response = strings.TrimSpace(response)
+	if response == "*" {
+		return "", errAuthCancelled
+	}
+
+	return response, nil
+}
+
+func decodeAuthResponse(response string) ([]byte, error) {
+	// a single "=" is an empty initial response (RFC 4954, section 4)
+	if response == "=" {
+		return []byte{}, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		return nil, errAuthMalformed
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,116 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/adampresley/webframework/sanitizer"
+
+	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+// BdatCommandExecutor process the command BDAT, which transfers a message in one or more chunks of a declared size
+// (RFC 3030).
+type BdatCommandExecutor struct {
+	logger         *slog.Logger
+	maxMessageSize int64
+	message        *DataCommandExecutor
+	reader         *Reader
+	session        *Session
+	writer         *Writer
+}
+
+// NewBdatCommandExecutor creates a new struct. A maxMessageSize of zero means messages of any size are accepted.
+func NewBdatCommandExecutor(
+	logger *slog.Logger,
+	reader *Reader,
+	writer *Writer,
+	session *Session,
+	emailValidationService mailslurper.EmailValidationProvider,
+	xssService sanitizer.IXSSServiceProvider,
+	maxMessageSize int64,
+	faults *FaultInjector,
+) *BdatCommandExecutor {
+	return &BdatCommandExecutor{
+		logger:         logger,
+		maxMessageSize: maxMessageSize,
+		message:        NewDataCommandExecutor(logger, reader, writer, emailValidationService, xssService, maxMessageSize, faults),
+		reader:         reader,
+		session:        session,
+		writer:         writer,
+	}
+}
+
+// Process handles the BDAT command. The command takes the form "BDAT <size> [LAST]" and is followed by exactly size
+// bytes of message data. Chunks are collected on the session, and once the last one arrives the message is parsed the
+// same way as one sent with DATA.
+func (e *BdatCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
+	var err error
+
+	if err = IsValidCommand(streamInput, "BDAT"); err != nil {
+		return err
+	}
+
+	size, last, err := ParseBdatCommand(streamInput)
+	if err != nil {
+		return err
+	}
+
+	if e.maxMessageSize > 0 && int64(e.session.Chunks.Len())+size > e.maxMessageSize {
+		if err = e.reader.ReadChunk(size, io.Discard); err != nil {
+			return err
+		}
+
+		e.logger.Info("Message rejected", "error", ErrMessageTooLarge, "maxMessageSize", e.maxMessageSize)
+
+		return e.message.rejectMessage(mailItem, MessageTooLarge())
+	}
+
+	if err = e.reader.ReadChunk(size, &e.session.Chunks); err != nil {
+		return err
+	}
+
+	if last {
+		if err = e.message.processMessage(e.session.Chunks.String(), mailItem); err != nil {
+			return err
+		}
+	}
+
+	return e.writer.SendOkResponse()
+}
+
+// Discard reads and throws away the chunk data that follows a BDAT command which is refused before it is processed,
+// so the data is not mistaken for commands.
+func (e *BdatCommandExecutor) Discard(streamInput string) error {
+	size, _, err := ParseBdatCommand(streamInput)
+	if err != nil {
+		return nil
+	}
+
+	return e.reader.ReadChunk(size, io.Discard)
+}
+
+// ParseBdatCommand returns the chunk size of a BDAT command and whether it carries the last chunk of the message.
+func ParseBdatCommand(streamInput string) (int64, bool, error) {
+	split := strings.Fields(streamInput)
+	if len(split) < 2 || len(split) > 3 {
+		return 0, false, InvalidCommandFormat("BDAT")
+	}
+
+	size, err := strconv.ParseInt(split[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, false, InvalidCommandFormat("BDAT")
+	}
+
+	if len(split) == 3 && !strings.EqualFold(split[2], "LAST") {
+		return 0, false, InvalidCommandFormat("BDAT")
+	}
+
+	return size, len(split) == 3, nil
+}
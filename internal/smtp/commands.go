@@ -13,14 +13,19 @@ import (
 type Command int
 
 const (
-	NONE Command = iota
-	RCPT Command = iota
-	MAIL Command = iota
-	HELO Command = iota
-	RSET Command = iota
-	DATA Command = iota
-	QUIT Command = iota
-	NOOP Command = iota
+	NONE     Command = iota
+	RCPT     Command = iota
+	MAIL     Command = iota
+	HELO     Command = iota
+	RSET     Command = iota
+	DATA     Command = iota
+	QUIT     Command = iota
+	NOOP     Command = iota
+	STARTTLS Command = iota
+	AUTH     Command = iota
+	BDAT     Command = iota
+	// BDAT_LAST is a BDAT command carrying the final chunk of a message.
+	BDAT_LAST Command = iota
 )
 
 // Commands is a map of SMTP command strings to their int representation. This is primarily used because there can be
@@ -36,17 +41,28 @@ var Commands = map[string]Command{
 	"quit":      QUIT,
 	"data":      DATA,
 	"noop":      NOOP,
+	"starttls":  STARTTLS,
+	"auth":      AUTH,
+	"bdat":      BDAT,
 }
 
+// UnimplementedCommands are commands defined by RFC 5321 that are understood but not supported. They are answered with
+// 502 rather than the 500 sent for unrecognized input.
+var UnimplementedCommands = []string{"vrfy", "expn", "help", "turn"}
+
 // CommandsToStrings is a friendly string representations of commands. Useful in error reporting.
 var CommandsToStrings = map[Command]string{
-	HELO: "HELO",
-	RCPT: "RCPT TO",
-	MAIL: "SEND",
-	RSET: "RSET",
-	QUIT: "QUIT",
-	DATA: "DATA",
-	NOOP: "NOOP",
+	HELO:      "HELO",
+	RCPT:      "RCPT TO",
+	MAIL:      "MAIL FROM",
+	RSET:      "RSET",
+	QUIT:      "QUIT",
+	DATA:      "DATA",
+	NOOP:      "NOOP",
+	STARTTLS:  "STARTTLS",
+	AUTH:      "AUTH",
+	BDAT:      "BDAT",
+	BDAT_LAST: "BDAT LAST",
 }
 
 // GetCommandFromString takes a string and returns the integer command representation. For example if the string
@@ -70,6 +86,12 @@ func GetCommandFromString(input string) (Command, error) {
 		return result, fmt.Errorf("Command '%s' not found", input)
 	}
 
+	if result == BDAT {
+		if _, last, err := ParseBdatCommand(input); err == nil && last {
+			result = BDAT_LAST
+		}
+	}
+
 	return result, nil
 }
 
@@ -4,16 +4,51 @@
 
 package smtp
 
-// Responses that are sent to SMTP clients.
+// Responses that are sent to SMTP clients. Replies other than the greeting and HELO/EHLO carry an enhanced status code
+// (RFC 3463), as promised by the ENHANCEDSTATUSCODES extension.
 const (
-	SMTP_CRLF                     string = "\r\n"
-	SMTP_DATA_TERMINATOR          string = "\r\n.\r\n"
-	SMTP_WELCOME_MESSAGE          string = "220 Welcome to MailSlurper!"
-	SMTP_CLOSING_MESSAGE          string = "221 Bye"
-	SMTP_OK_MESSAGE               string = "250 Ok"
-	SMTP_DATA_RESPONSE_MESSAGE    string = "354 End data with <CR><LF>.<CR><LF>"
-	SMTP_HELLO_RESPONSE_MESSAGE   string = "250 Hello. How very nice to meet you!"
-	SMTP_ERROR_TRANSACTION_FAILED string = "554 Transaction failed"
+	SMTP_CRLF                   string = "\r\n"
+	SMTP_WELCOME_MESSAGE        string = "220 Welcome to MailSlurper!"
+	SMTP_CLOSING_MESSAGE        string = "221 2.0.0 Bye"
+	SMTP_OK_MESSAGE             string = "250 2.0.0 Ok"
+	SMTP_DATA_RESPONSE_MESSAGE  string = "354 End data with <CR><LF>.<CR><LF>"
+	SMTP_HELLO_GREETING         string = "Hello. How very nice to meet you!"
+	SMTP_HELLO_RESPONSE_MESSAGE string = "250 " + SMTP_HELLO_GREETING
+	SMTP_STARTTLS_READY_MESSAGE string = "220 2.0.0 Ready to start TLS"
+	SMTP_TLS_REQUIRED_MESSAGE   string = "530 5.7.0 Must issue a STARTTLS command first"
+	SMTP_AUTH_CHALLENGE_PREFIX  string = "334 "
+	SMTP_AUTH_SUCCESS_MESSAGE   string = "235 2.7.0 Authentication successful"
+	SMTP_AUTH_FAILED_MESSAGE    string = "535 5.7.8 Authentication credentials invalid"
+	SMTP_AUTH_CANCELLED_MESSAGE string = "501 5.7.0 Authentication cancelled"
+	SMTP_AUTH_MALFORMED_MESSAGE string = "501 5.5.2 Cannot decode authentication response"
+	SMTP_AUTH_MECHANISM_MESSAGE string = "504 5.5.4 Unrecognized authentication mechanism"
+	SMTP_AUTH_ALREADY_MESSAGE   string = "503 5.5.1 Already authenticated"
+)
+
+// SMTP reply codes.
+const (
+	SMTP_REPLY_OK                        int = 250
+	SMTP_REPLY_LOCAL_ERROR               int = 451
+	SMTP_REPLY_COMMAND_UNRECOGNIZED      int = 500
+	SMTP_REPLY_SYNTAX_ERROR              int = 501
+	SMTP_REPLY_COMMAND_NOT_IMPLEMENTED   int = 502
+	SMTP_REPLY_BAD_SEQUENCE              int = 503
+	SMTP_REPLY_EXCEEDED_STORAGE          int = 552
+	SMTP_REPLY_MAILBOX_NAME_NOT_ALLOWED  int = 553
+	SMTP_REPLY_TRANSACTION_FAILED        int = 554
+	SMTP_REPLY_PARAMETER_NOT_IMPLEMENTED int = 555
+)
+
+// Service extensions that may be advertised in response to EHLO.
+const (
+	SMTP_EXTENSION_SIZE                string = "SIZE"
+	SMTP_EXTENSION_8BITMIME            string = "8BITMIME"
+	SMTP_EXTENSION_PIPELINING          string = "PIPELINING"
+	SMTP_EXTENSION_CHUNKING            string = "CHUNKING"
+	SMTP_EXTENSION_SMTPUTF8            string = "SMTPUTF8"
+	SMTP_EXTENSION_ENHANCEDSTATUSCODES string = "ENHANCEDSTATUSCODES"
+	SMTP_EXTENSION_AUTH                string = "AUTH"
+	SMTP_EXTENSION_STARTTLS            string = "STARTTLS"
 )
 
 // SMTPWorkerState defines states that a worker may be in. Typically a worker starts IDLE, the moves to WORKING, finally
@@ -26,7 +61,9 @@ const (
 	SMTP_WORKER_DONE    SMTPWorkerState = 100
 	SMTP_WORKER_ERROR   SMTPWorkerState = 101
 
-	RECEIVE_BUFFER_LEN         = 1024
-	CONNECTION_TIMEOUT_MINUTES = 10
-	COMMAND_TIMEOUT_SECONDS    = 5
+	RECEIVE_BUFFER_LEN            = 64 * 1024
+	MAX_COMMAND_LINE_LEN          = 12288
+	CONNECTION_TIMEOUT_MINUTES    = 10
+	COMMAND_TIMEOUT_SECONDS       = 5
+	TLS_HANDSHAKE_TIMEOUT_SECONDS = 10
 )
@@ -22,23 +22,30 @@ import (
 // DataCommandExecutor process the Data TO command.
 type DataCommandExecutor struct {
 	emailValidationService mailslurper.EmailValidationProvider
+	faults                 *FaultInjector
 	logger                 *slog.Logger
+	maxMessageSize         int64
 	reader                 *Reader
 	writer                 *Writer
 	xssService             sanitizer.IXSSServiceProvider
 }
 
-// NewDataCommandExecutor creates a new struct.
+// NewDataCommandExecutor creates a new struct. A maxMessageSize of zero means messages of any size are accepted.
+// Messages may be refused, or the connection dropped, on purpose by the fault injector.
 func NewDataCommandExecutor(
 	logger *slog.Logger,
 	reader *Reader,
 	writer *Writer,
 	emailValidationService mailslurper.EmailValidationProvider,
 	xssService sanitizer.IXSSServiceProvider,
+	maxMessageSize int64,
+	faults *FaultInjector,
 ) *DataCommandExecutor {
 	return &DataCommandExecutor{
 		emailValidationService: emailValidationService,
+		faults:                 faults,
 		logger:                 logger,
+		maxMessageSize:         maxMessageSize,
 		reader:                 reader,
 		writer:                 writer,
 		xssService:             xssService,
@@ -64,29 +71,67 @@ func NewDataCommandExecutor(
 func (e *DataCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
 	var err error
 
-	commandCheck := strings.Index(strings.ToLower(streamInput), "data")
-	if commandCheck < 0 {
-		return errors.New("Invalid command for DATA")
+	if strings.ToLower(streamInput) != "data" {
+		return InvalidCommandFormat("DATA")
 	}
 
 	e.writer.SendDataResponse()
 
-	entireMailContents, err := e.reader.ReadDataBlock()
+	if e.faults.DropConnection() {
+		// wait for the first line of the message, so the client loses the connection part way through sending it
+		_, _ = e.reader.readLine(0)
+
+		e.logger.Debug("Connection dropped by fault injection")
+
+		_ = e.reader.Connection.Close()
+
+		return ErrConnectionDropped
+	}
+
+	entireMailContents, err := e.reader.ReadDataBlock(e.maxMessageSize)
+	if errors.Is(err, ErrMessageTooLarge) {
+		e.logger.Info("Message rejected", "error", err, "maxMessageSize", e.maxMessageSize)
+
+		return e.rejectMessage(mailItem, MessageTooLarge())
+	}
+
 	if err != nil {
 		return fmt.Errorf("Error in DataCommandExecutor: %w", err)
 	}
 
+	if err = e.processMessage(entireMailContents, mailItem); err != nil {
+		return err
+	}
+
+	return e.writer.SendOkResponse()
+}
+
+// processMessage parses the complete message contents into the mail item. It is shared by DATA and BDAT, which only
+// differ in how the contents are transferred.
+func (e *DataCommandExecutor) processMessage(entireMailContents string, mailItem *model.MailItem) error {
+	var err error
+
+	if reply := e.faults.Message(); reply != nil {
+		e.logger.Debug("Message refused by fault injection", "reply", reply.String())
+
+		return e.rejectMessage(mailItem, reply)
+	}
+
+	mailItem.Raw = []byte(entireMailContents)
+	mailItem.Headers = model.NewMailHeaders(mailItem.ID, entireMailContents)
+
 	if err = mailItem.Message.BuildMessages(entireMailContents); err != nil {
 		e.logger.Error(fmt.Sprintf("Problem parsing message contents: %s", err.Error()))
-		e.writer.SendResponse(SMTP_ERROR_TRANSACTION_FAILED)
 
-		return fmt.Errorf("Problem parsing message contents: %w", err)
+		return e.rejectMessage(mailItem, TransactionFailed())
 	}
 
 	mailItem.Subject = e.getSubjectFromPart(mailItem.Message)
 	mailItem.DateSent = mailslurper.ParseDateTime(mailItem.Message.GetHeader("Date"), e.logger)
 	mailItem.ContentType = mailItem.Message.GetHeader("Content-Type")
 	mailItem.TransferEncoding = mailItem.Message.GetHeader("Content-Transfer-Encoding")
+	mailItem.SetHeaderAddresses(mailItem.Message)
+	mailItem.PartTree = model.NewMessagePartTree(mailItem.ID, mailItem.Message)
 
 	if len(mailItem.Message.MessageParts) > 0 {
 		for _, m := range mailItem.Message.MessageParts {
@@ -100,17 +145,19 @@ func (e *DataCommandExecutor) Process(streamInput string, mailItem *model.MailIt
 		}
 
 	} else {
-		if mailItem.Body, err = e.getBodyContent(mailItem.Message.GetBody()); err != nil {
-			e.writer.SendResponse(SMTP_ERROR_TRANSACTION_FAILED)
+		// the body is whatever follows the headers, which may be nothing at all
+		if mailItem.Body, err = e.decodeBody(mailItem.Message.GetBody(), mailItem.ContentType, mailItem.TransferEncoding); err != nil {
+			e.logger.Error("Problem decoding body", "error", err)
 
-			return fmt.Errorf("Problem reading body: %w", err)
+			return e.rejectMessage(mailItem, TransactionFailed())
 		}
-	}
-
-	if mailItem.Body, err = e.decodeBody(mailItem.Body, mailItem.ContentType, mailItem.TransferEncoding); err != nil {
-		e.writer.SendResponse(SMTP_ERROR_TRANSACTION_FAILED)
 
-		return fmt.Errorf("Problem decoding body: %w", err)
+		switch {
+		case e.isMIMEType(mailItem.Message, "text/html"):
+			mailItem.HTMLBody = mailItem.Body
+		case e.isMIMEType(mailItem.Message, "text/plain") || mailItem.ContentType == "":
+			mailItem.TextBody = mailItem.Body
+		}
 	}
 
 	e.logger.Debug(fmt.Sprintf("Subject: %s", mailItem.Subject))
@@ -119,11 +166,17 @@ func (e *DataCommandExecutor) Process(streamInput string, mailItem *model.MailIt
 	e.logger.Debug(fmt.Sprintf("Body: %s", mailItem.Body))
 	e.logger.Debug(fmt.Sprintf("Transfer Encoding: %s", mailItem.TransferEncoding))
 
-	e.writer.SendOkResponse()
-
 	return nil
 }
 
+// rejectMessage ends the mail transaction after a message could not be accepted. The client is sent the reply and may
+// start a new transaction on the same connection.
+func (e *DataCommandExecutor) rejectMessage(mailItem *model.MailItem, reply *ReplyError) error {
+	*mailItem = *model.NewEmptyMailItem(e.logger)
+
+	return MessageRejected(reply)
+}
+
 func (e *DataCommandExecutor) addAttachment(messagePart model.ISMTPMessagePart, mailItem *model.MailItem) error {
 	headers := &model.AttachmentHeader{
 		ContentType:             messagePart.GetHeader("Content-Type"),
@@ -136,56 +189,80 @@ func (e *DataCommandExecutor) addAttachment(messagePart model.ISMTPMessagePart,
 
 	e.logger.Debug(fmt.Sprintf("Adding attachment: %v", headers))
 
-	attachment := model.NewAttachment(headers, messagePart.GetBody(), e.xssService)
+	attachment := model.NewAttachment(headers, e.getPartBody(messagePart), e.xssService)
+	attachment.ContentID = strings.Trim(strings.TrimSpace(messagePart.GetHeader("Content-ID")), "<>")
+	attachment.Inline = !e.messagePartIsAttachment(messagePart)
 
 	if e.messagePartIsAttachment(messagePart) {
-		mailItem.Attachments = append(mailItem.Attachments, attachment)
+		mailItem.Attachments = append(mailItem.Attachments, *attachment)
 	} else {
-		mailItem.InlineAttachments = append(mailItem.InlineAttachments, attachment)
+		mailItem.InlineAttachments = append(mailItem.InlineAttachments, *attachment)
 	}
 
 	return nil
 }
 
+// decodeBody removes the transfer encoding from a single part body and converts it to UTF-8. The body is otherwise
+// left as sent; plain text is only turned into HTML when it is displayed.
 func (e *DataCommandExecutor) decodeBody(body, contentType, transferEncoding string) (string, error) {
 	var result []byte
 	var err error
 
-	switch transferEncoding {
+	switch strings.ToLower(transferEncoding) {
 	case "base64":
 		if result, err = base64.StdEncoding.DecodeString(body); err != nil {
 			return body, err
 		}
-		break
 	case "quoted-printable":
 		if result, err = ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(body))); err != nil {
 			return body, err
 		}
-		break
-
 	default:
 		result = []byte(body)
-		break
 	}
 
-	if strings.Contains(contentType, "text/plain") {
-		result = []byte(strings.Replace(string(result), "\n", "<br />", -1))
+	return string(e.decodeCharset(result, contentType)), nil
+}
+
+// decodeCharset converts a body to UTF-8. A body in an unknown charset is kept as sent rather than refusing the message.
+func (e *DataCommandExecutor) decodeCharset(body []byte, contentType string) []byte {
+	decoded, err := model.DecodeCharset(body, contentType)
+	if err != nil {
+		e.logger.Debug("Problem decoding body charset", "contentType", contentType, "error", err)
+
+		return body
+	}
+
+	return decoded
+}
+
+// getPartBody returns the body of a nested part with quoted-printable decoded. Base64 is left for the readers of the
+// body and attachment contents to decode.
+func (e *DataCommandExecutor) getPartBody(messagePart model.ISMTPMessagePart) string {
+	if !strings.EqualFold(strings.TrimSpace(messagePart.GetHeader("Content-Transfer-Encoding")), "quoted-printable") {
+		return messagePart.GetBody()
+	}
+
+	body, err := messagePart.GetDecodedBody()
+	if err != nil {
+		e.logger.Debug("Problem decoding quoted-printable part", "error", err)
+
+		return messagePart.GetBody()
 	}
 
-	return string(result), nil
+	return string(body)
 }
 
-func (e *DataCommandExecutor) getBodyContent(contents string) (string, error) {
-	/*
-	 * Split the DATA content by CRLF CRLF. The first item will be the data
-	 * headers. Everything past that is body/message.
-	 */
-	headerBodySplit := strings.Split(contents, "\r\n\r\n")
-	if len(headerBodySplit) < 2 {
-		return "", errors.New("Expected DATA block to contain a header section and a body section")
+// getTextBody returns the body of a text part with its transfer encoding removed and converted to UTF-8.
+func (e *DataCommandExecutor) getTextBody(messagePart model.ISMTPMessagePart) string {
+	body, err := messagePart.GetDecodedBody()
+	if err != nil {
+		e.logger.Debug("Problem decoding text part", "error", err)
+
+		return messagePart.GetBody()
 	}
 
-	return strings.Join(headerBodySplit[1:], "\r\n\r\n"), nil
+	return string(e.decodeCharset(body, messagePart.GetHeader("Content-Type")))
 }
 
 func (e *DataCommandExecutor) getSubjectFromPart(part *model.SMTPMessagePart) string {
@@ -208,10 +285,10 @@ func (e *DataCommandExecutor) messagePartIsAttachment(messagePart model.ISMTPMes
 
 func (e *DataCommandExecutor) recordMessagePart(message model.ISMTPMessagePart, mailItem *model.MailItem) error {
 	if e.isMIMEType(message, "text/plain") && mailItem.TextBody == "" && !e.messagePartIsAttachment(message) {
-		mailItem.TextBody = message.GetBody()
+		mailItem.TextBody = e.getTextBody(message)
 	} else {
 		if e.isMIMEType(message, "text/html") && mailItem.HTMLBody == "" && !e.messagePartIsAttachment(message) {
-			mailItem.HTMLBody = message.GetBody()
+			mailItem.HTMLBody = e.getTextBody(message)
 		} else {
 			if e.isMIMEType(message, "multipart") {
 				for _, m := range message.GetMessageParts() {
@@ -10,7 +10,14 @@ import (
 )
 
 var (
-	ErrServerClosed = errors.New("server closed")
+	ErrServerClosed      = errors.New("server closed")
+	ErrMessageTooLarge   = errors.New("message exceeds the maximum message size")
+	ErrLineTooLong       = errors.New("line exceeds the maximum line length")
+	ErrConnectionDropped = errors.New("connection dropped by fault injection")
+	ErrReaderStopped     = errors.New("reader stopped")
+
+	errAuthCancelled = errors.New("authentication cancelled by client")
+	errAuthMalformed = errors.New("malformed authentication response")
 )
 
 /*
@@ -113,3 +120,106 @@ func InvalidCommand(command string) *InvalidCommandError {
 func (err *InvalidCommandError) Error() string {
 	return fmt.Sprintf("Invalid command %s", err.InvalidCommand)
 }
+
+/*
+An UnsupportedParameterError is used to alert a client that a MAIL FROM or
+RCPT TO parameter is not recognized or has an invalid value
+*/
+type UnsupportedParameterError struct {
+	Parameter string
+}
+
+/*
+UnsupportedParameter returns a new error object
+*/
+func UnsupportedParameter(parameter string) *UnsupportedParameterError {
+	return &UnsupportedParameterError{
+		Parameter: parameter,
+	}
+}
+
+func (err *UnsupportedParameterError) Error() string {
+	return fmt.Sprintf("Parameter '%s' is not supported", err.Parameter)
+}
+
+/*
+A ReplyError is a failure that is reported to the client as an SMTP reply
+rather than ending the session. It carries the reply code and the enhanced
+status code (RFC 3463) sent along with the message.
+*/
+type ReplyError struct {
+	Code         int
+	EnhancedCode string
+	Message      string
+}
+
+/*
+Reply returns a new error object
+*/
+func Reply(code int, enhancedCode, message string) *ReplyError {
+	return &ReplyError{
+		Code:         code,
+		EnhancedCode: enhancedCode,
+		Message:      message,
+	}
+}
+
+func (err *ReplyError) Error() string {
+	return err.String()
+}
+
+/*
+String formats the error as a reply line, without the trailing CRLF
+*/
+func (err *ReplyError) String() string {
+	return fmt.Sprintf("%d %s %s", err.Code, err.EnhancedCode, err.Message)
+}
+
+/*
+A BadSequenceError is used to alert a client that a command was sent out of
+order, such as DATA before any RCPT TO
+*/
+type BadSequenceError struct {
+	Command string
+	Reason  string
+}
+
+/*
+BadSequence returns a new error object
+*/
+func BadSequence(command, reason string) *BadSequenceError {
+	return &BadSequenceError{
+		Command: command,
+		Reason:  reason,
+	}
+}
+
+func (err *BadSequenceError) Error() string {
+	return fmt.Sprintf("Bad sequence of commands: %s %s", err.Command, err.Reason)
+}
+
+/*
+A MessageRejectedError is used when a message sent with DATA was received in
+full but could not be accepted. The mail transaction is over and the client
+is sent the wrapped reply.
+*/
+type MessageRejectedError struct {
+	Reply *ReplyError
+}
+
+/*
+MessageRejected returns a new error object
+*/
+func MessageRejected(reply *ReplyError) *MessageRejectedError {
+	return &MessageRejectedError{
+		Reply: reply,
+	}
+}
+
+func (err *MessageRejectedError) Error() string {
+	return fmt.Sprintf("Message rejected: %s", err.Reply.String())
+}
+
+func (err *MessageRejectedError) Unwrap() error {
+	return err.Reply
+}
@@ -0,0 +1,131 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+)
+
+// Default replies for injected faults.
+const (
+	FAULT_DEFAULT_RATE_CODE      int    = 451
+	FAULT_DEFAULT_RECIPIENT_CODE int    = 550
+	FAULT_DEFAULT_MESSAGE        string = "Failure injected by MailSlurper"
+)
+
+// FaultInjector makes the SMTP server misbehave on demand so clients can test their retry and bounce handling. It is
+// shared by all workers, and its rules may be replaced at any time while the server is running.
+type FaultInjector struct {
+	lock    sync.RWMutex
+	rules   slurperio.FaultConfig
+	latency time.Duration
+	random  func() float64
+}
+
+// NewFaultInjector creates a fault injector starting with the configured rules, which are expected to be valid.
+func NewFaultInjector(rules slurperio.FaultConfig) *FaultInjector {
+	return &FaultInjector{
+		rules:   rules,
+		latency: rules.GetLatency(),
+		random:  rand.Float64,
+	}
+}
+
+// Rules returns the rules currently in effect.
+func (f *FaultInjector) Rules() slurperio.FaultConfig {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.rules
+}
+
+// SetRules validates and replaces the rules. Connections in progress pick up the new rules with their next command.
+func (f *FaultInjector) SetRules(rules slurperio.FaultConfig) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.rules = rules
+	f.latency = rules.GetLatency()
+
+	return nil
+}
+
+// Delay sleeps for the configured latency. It is called before a command is answered.
+func (f *FaultInjector) Delay() {
+	f.lock.RLock()
+	latency := f.latency
+	f.lock.RUnlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// Recipient returns the reply for a recipient that should be refused, or nil to accept it. Recipient patterns are
+// checked before the RCPT failure rate.
+func (f *FaultInjector) Recipient(address string) *ReplyError {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	for _, recipient := range f.rules.Recipients {
+		if matched, _ := path.Match(strings.ToLower(recipient.Pattern), strings.ToLower(address)); matched {
+			return faultReply(recipient.Code, FAULT_DEFAULT_RECIPIENT_CODE, recipient.Message)
+		}
+	}
+
+	return f.rate(f.rules.Rcpt)
+}
+
+// Message returns the reply for a received message that should be refused, or nil to accept it.
+func (f *FaultInjector) Message() *ReplyError {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.rate(f.rules.Data)
+}
+
+// DropConnection returns true if the connection should be closed during DATA.
+func (f *FaultInjector) DropConnection() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.roll(f.rules.DropPercent)
+}
+
+func (f *FaultInjector) rate(rate slurperio.FaultRate) *ReplyError {
+	if !f.roll(rate.Percent) {
+		return nil
+	}
+
+	return faultReply(rate.Code, FAULT_DEFAULT_RATE_CODE, rate.Message)
+}
+
+func (f *FaultInjector) roll(percent float64) bool {
+	return percent > 0 && f.random()*100 < percent
+}
+
+// faultReply builds an injected reply. The enhanced status code only carries the class of the reply code, as an
+// injected failure has no real subject or detail.
+func faultReply(code, defaultCode int, message string) *ReplyError {
+	if code == 0 {
+		code = defaultCode
+	}
+
+	if message == "" {
+		message = FAULT_DEFAULT_MESSAGE
+	}
+
+	return Reply(code, fmt.Sprintf("%d.0.0", code/100), message)
+}
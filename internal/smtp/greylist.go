@@ -0,0 +1,65 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/pkg/cache"
+)
+
+// Greylister simulates greylisting. The first delivery attempt for a (client IP, sender, recipient) triplet is refused
+// with a temporary failure, and attempts for the same triplet are accepted once the delay has passed. It is shared by
+// all workers.
+type Greylister struct {
+	cache  cache.ICacheService
+	delay  time.Duration
+	expiry time.Duration
+	now    func() time.Time
+	lock   sync.Mutex
+}
+
+// NewGreylister creates a greylister keeping triplet state in the cache.
+func NewGreylister(cacheService cache.ICacheService, config slurperio.GreylistConfig) *Greylister {
+	return &Greylister{
+		cache:  cacheService,
+		delay:  config.GetDelay(),
+		expiry: config.GetExpiry(),
+		now:    time.Now,
+	}
+}
+
+// Check returns the reply refusing a delivery attempt, or nil to accept it. The time the triplet was first seen is kept
+// for as long as the triplet keeps being used, so a client that has passed once is not greylisted again.
+func (g *Greylister) Check(clientIP, sender, recipient string) *ReplyError {
+	key := strings.Join([]string{"greylist", clientIP, strings.ToLower(sender), strings.ToLower(recipient)}, "\x00")
+	now := g.now()
+
+	/*
+	 * The cache only locks each call on its own. Reading and updating a
+	 * triplet is one step here, so two connections trying the same triplet
+	 * at once cannot both miss it and move the time it was first seen.
+	 */
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	value, ok := g.cache.Get(key)
+	if !ok {
+		g.cache.Set(key, now, g.expiry)
+
+		return Greylisted()
+	}
+
+	firstSeen, _ := value.(time.Time)
+	if now.Sub(firstSeen) < g.delay {
+		return Greylisted()
+	}
+
+	g.cache.Set(key, firstSeen, g.expiry)
+
+	return nil
+}
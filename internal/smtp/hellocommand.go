@@ -5,7 +5,6 @@
 package smtp
 
 import (
-	"fmt"
 	"log/slog"
 	"strings"
 
@@ -14,32 +13,51 @@ import (
 
 // HelloCommandExecutor process the commands EHLO, HELO.
 type HelloCommandExecutor struct {
-	logger *slog.Logger
-	reader *Reader
-	writer *Writer
+	extensions []string
+	logger     *slog.Logger
+	reader     *Reader
+	writer     *Writer
+	session    *Session
 }
 
-// NewHelloCommandExecutor creates a new struct.
-func NewHelloCommandExecutor(logger *slog.Logger, reader *Reader, writer *Writer) *HelloCommandExecutor {
+// NewHelloCommandExecutor creates a new struct. The extensions are advertised to clients that greet with EHLO.
+func NewHelloCommandExecutor(
+	logger *slog.Logger,
+	reader *Reader,
+	writer *Writer,
+	session *Session,
+	extensions []string,
+) *HelloCommandExecutor {
 	return &HelloCommandExecutor{
-		logger: logger,
-		reader: reader,
-		writer: writer,
+		extensions: extensions,
+		logger:     logger,
+		reader:     reader,
+		writer:     writer,
+		session:    session,
 	}
 }
 
-// Process handles the HELO greeting command.
+// Process handles the HELO and EHLO greeting commands. The domain the client identifies itself with is recorded on the
+// session. HELO gets a single line reply, while EHLO also lists the supported service extensions. Either command
+// abandons any mail transaction in progress (RFC 5321, section 4.1.4).
 func (e *HelloCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
 	lowercaseStreamInput := strings.ToLower(streamInput)
 
 	commandCheck := (strings.Index(lowercaseStreamInput, "helo") + 1) + (strings.Index(lowercaseStreamInput, "ehlo") + 1)
 	if commandCheck <= 0 {
-		return fmt.Errorf("Invalid HELO command")
+		return InvalidCommand("HELO")
 	}
 
-	split := strings.Split(streamInput, " ")
+	split := strings.Fields(streamInput)
 	if len(split) < 2 {
-		return fmt.Errorf("HELO command format is invalid")
+		return InvalidCommandFormat("HELO")
+	}
+
+	e.session.HeloDomain = split[1]
+	*mailItem = *model.NewEmptyMailItem(e.logger)
+
+	if strings.HasPrefix(lowercaseStreamInput, "ehlo") {
+		return e.writer.SendEHLOResponse(e.extensions)
 	}
 
 	return e.writer.SendHELOResponse()
@@ -23,13 +23,14 @@ import (
 // created to handle processing the mail on this connection.
 type Listener struct {
 	certificate         tls.Certificate
-	config              slurperio.ListenConfig
+	config              slurperio.SMTPConfig
 	connectionManager   mailslurper.IConnectionManager
 	killListenerChannel chan bool
 	killRecieverChannel chan bool
 	listener            net.Listener
 	logger              *slog.Logger
 	mailItemChannel     chan *model.MailItem
+	storageReceiver     mailslurper.IMailItemReceiver
 	receivers           []mailslurper.IMailItemReceiver
 	serverPool          *ServerPool
 
@@ -38,12 +39,14 @@ type Listener struct {
 	running atomic.Bool
 }
 
-// NewListener creates an Listener struct.
+// NewListener creates an Listener struct. Mail items go to the storage receiver first, and only mail it stores goes on
+// to the other receivers. A nil storage receiver passes every mail item straight on.
 func NewListener(
 	logger *slog.Logger,
-	config slurperio.ListenConfig,
+	config slurperio.SMTPConfig,
 	mailItemChannel chan *model.MailItem,
 	serverPool *ServerPool,
+	storageReceiver mailslurper.IMailItemReceiver,
 	receivers []mailslurper.IMailItemReceiver,
 	connectionManager mailslurper.IConnectionManager,
 ) (*Listener, error) {
@@ -56,12 +59,13 @@ func NewListener(
 		killRecieverChannel: make(chan bool, 1),
 		logger:              logger,
 		mailItemChannel:     mailItemChannel,
+		storageReceiver:     storageReceiver,
 		receivers:           receivers,
 		serverPool:          serverPool,
 		chClose:             make(chan struct{}),
 	}
 
-	if config.CertFile != "" && config.KeyFile != "" {
+	if config.IsSSL() {
 		if result.certificate, err = tls.LoadX509KeyPair(config.CertFile, config.KeyFile); err != nil {
 			return result, errors.Wrapf(err, "Error loading X509 certificate key pair while setting up SMTP listener")
 		}
@@ -143,15 +147,14 @@ func (l *Listener) openListener() error {
 	return nil
 }
 
+// startReceivers hands each mail item to the receivers. Mail items are handled concurrently.
 func (l *Listener) startReceivers() {
 	l.logger.Info(fmt.Sprintf("%d receiver(s) listening", len(l.receivers)))
 
 	for {
 		select {
 		case item := <-l.mailItemChannel:
-			for _, r := range l.receivers {
-				go r.Receive(item)
-			}
+			go l.receive(item)
 		case <-l.chClose:
 			l.logger.Info("Shutting down receiver channel...")
 
@@ -160,6 +163,27 @@ func (l *Listener) startReceivers() {
 	}
 }
 
+// receive passes a mail item to the storage receiver, and then to the other receivers at the same time once it is
+// stored. Mail that could not be stored goes no further, so the other receivers only ever see stored mail. A slow or
+// failing receiver does not hold up the others.
+func (l *Listener) receive(item *model.MailItem) {
+	if l.storageReceiver != nil {
+		if err := l.storageReceiver.Receive(item); err != nil {
+			l.logger.Error(fmt.Sprintf("Mail item %s not stored and not passed on to the receivers: %s", item.ID, err))
+
+			return
+		}
+	}
+
+	for _, r := range l.receivers {
+		go func() {
+			if err := r.Receive(item); err != nil {
+				l.logger.Error(fmt.Sprintf("Receiver failed to handle mail item %s: %s", item.ID, err))
+			}
+		}()
+	}
+}
+
 func (l *Listener) acceptConnections() {
 	for {
 		select {
@@ -0,0 +1,104 @@
+package smtp_test
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/io"
+	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/internal/smtp"
+)
+
+// receiverFunc lets a plain function stand in for a receiver.
+type receiverFunc func(mailItem *model.MailItem) error
+
+func (f receiverFunc) Receive(mailItem *model.MailItem) error {
+	return f(mailItem)
+}
+
+func TestListener_Receivers(t *testing.T) {
+	t.Parallel()
+
+	errReceiver := errors.New("receiver failed")
+
+	tests := []struct {
+		name       string
+		storageErr error
+		expected   int
+	}{
+		{
+			name:     "stored mail reaches every receiver",
+			expected: 2,
+		},
+		{
+			name:       "mail that is not stored goes no further",
+			storageErr: errReceiver,
+			expected:   0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			chReceived := make(chan uuid.UUID, 3)
+			record := receiverFunc(func(mailItem *model.MailItem) error {
+				chReceived <- mailItem.ID
+
+				return nil
+			})
+
+			// a failing receiver sits between the others, and must not keep the mail from them
+			receivers := []mailslurper.IMailItemReceiver{
+				record,
+				receiverFunc(func(*model.MailItem) error { return errReceiver }),
+				record,
+			}
+			chStored := make(chan struct{}, 1)
+			storage := receiverFunc(func(*model.MailItem) error {
+				chStored <- struct{}{}
+
+				return test.storageErr
+			})
+
+			chMailItem := make(chan *model.MailItem, 1)
+			config := io.SMTPConfig{ListenConfig: io.ListenConfig{Address: "127.0.0.1", Port: 0}}
+			logger := slog.New(slog.DiscardHandler)
+
+			listener, err := smtp.NewListener(logger, config, chMailItem, nil, storage, receivers, nil)
+			require.NoError(t, err)
+
+			go func() {
+				assert.ErrorIs(t, listener.ListenAndServe(), smtp.ErrServerClosed)
+			}()
+
+			mailItem := &model.MailItem{ID: uuid.Must(uuid.NewV4())}
+			chMailItem <- mailItem
+			<-chStored
+
+			received := 0
+			timeout := time.After(time.Second)
+
+		wait:
+			for {
+				select {
+				case id := <-chReceived:
+					assert.Equal(t, mailItem.ID, id)
+					received++
+				case <-timeout:
+					break wait
+				}
+			}
+
+			assert.Equal(t, test.expected, received)
+			require.NoError(t, listener.Close())
+		})
+	}
+}
@@ -6,7 +6,8 @@ package smtp
 
 import (
 	"log/slog"
-	"net/mail"
+	"strconv"
+	"strings"
 
 	"github.com/adampresley/webframework/sanitizer"
 
@@ -18,33 +19,40 @@ import (
 type MailCommandExecutor struct {
 	emailValidationService mailslurper.EmailValidationProvider
 	logger                 *slog.Logger
+	maxMessageSize         int64
 	reader                 *Reader
+	session                *Session
 	writer                 *Writer
 	xssService             sanitizer.IXSSServiceProvider
 }
 
-// NewMailCommandExecutor creates a new struct.
+// NewMailCommandExecutor creates a new struct. A maxMessageSize of zero means messages of any size are accepted.
 func NewMailCommandExecutor(
 	logger *slog.Logger,
 	reader *Reader,
 	writer *Writer,
+	session *Session,
 	emailValidationService mailslurper.EmailValidationProvider,
 	xssService sanitizer.IXSSServiceProvider,
+	maxMessageSize int64,
 ) *MailCommandExecutor {
 	return &MailCommandExecutor{
 		emailValidationService: emailValidationService,
 		logger:                 logger,
+		maxMessageSize:         maxMessageSize,
 		reader:                 reader,
+		session:                session,
 		writer:                 writer,
 		xssService:             xssService,
 	}
 }
 
-// Process handles the MAIL FROM command. This command tells us who the sender is.
+// Process handles the MAIL FROM command. This command tells us who the sender is. The BODY, SIZE, AUTH and SMTPUTF8
+// parameters of the advertised extensions are accepted after the reverse-path. The address is stored exactly as the
+// client sent it, and may only contain UTF-8 when the SMTPUTF8 parameter is given (RFC 6531).
 func (e *MailCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
 	var err error
 	var from string
-	var fromComponents *mail.Address
 
 	if err = IsValidCommand(streamInput, "MAIL FROM"); err != nil {
 		return err
@@ -54,21 +62,70 @@ func (e *MailCommandExecutor) Process(streamInput string, mailItem *model.MailIt
 		return err
 	}
 
+	from, parameters := SplitPathAndParameters(from)
+	if err = validateMailParameters(parameters); err != nil {
+		return err
+	}
+
+	if e.exceedsMaxMessageSize(parameters) {
+		return MessageTooLarge()
+	}
+
+	_, utf8 := parameters["SMTPUTF8"]
+
 	// For all we know, <> is a valid email address (RFC 2821, Section 6.1 & 3.7; NULL return path)
 	if from != "<>" {
-		if fromComponents, err = e.emailValidationService.GetEmailComponents(from); err != nil {
+		if !e.emailValidationService.IsValidEmail(from) {
 			return mailslurper.InvalidEmail(from)
 		}
 
-		from = e.xssService.SanitizeString(fromComponents.Address)
-
-		if !e.emailValidationService.IsValidEmail(from) {
-			return mailslurper.InvalidEmail(from)
+		if !utf8 && !IsASCII(from) {
+			return NonASCIIAddress()
 		}
+
+		from = UnwrapPath(from)
 	}
 
 	mailItem.FromAddress = from
-	e.writer.SendOkResponse()
+	e.session.UTF8 = utf8
+
+	return e.writer.SendOkResponse()
+}
+
+// validateMailParameters makes sure every MAIL FROM parameter belongs to an extension offered in the EHLO reply.
+func validateMailParameters(parameters map[string]string) error {
+	for keyword, value := range parameters {
+		switch keyword {
+		case "BODY":
+			if body := strings.ToUpper(value); body != "7BIT" && body != "8BITMIME" {
+				return UnsupportedParameter(keyword + "=" + value)
+			}
+		case "SIZE":
+			if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+				return UnsupportedParameter(keyword + "=" + value)
+			}
+		case "SMTPUTF8":
+			if value != "" {
+				return UnsupportedParameter(keyword + "=" + value)
+			}
+		case "AUTH":
+		default:
+			return UnsupportedParameter(keyword)
+		}
+	}
 
 	return nil
 }
+
+// exceedsMaxMessageSize returns true if the client declared a message size with the SIZE parameter that is larger than
+// the maximum message size (RFC 1870, section 6.1).
+func (e *MailCommandExecutor) exceedsMaxMessageSize(parameters map[string]string) bool {
+	value, ok := parameters["SIZE"]
+	if !ok || e.maxMessageSize <= 0 {
+		return false
+	}
+
+	size, _ := strconv.ParseUint(value, 10, 64)
+
+	return size > uint64(e.maxMessageSize)
+}
@@ -6,7 +6,7 @@ package smtp
 
 import (
 	"log/slog"
-	"net/mail"
+	"net"
 
 	"github.com/adampresley/webframework/sanitizer"
 
@@ -17,35 +17,45 @@ import (
 // RcptCommandExecutor process the RCPT TO command.
 type RcptCommandExecutor struct {
 	emailValidationService mailslurper.EmailValidationProvider
+	faults                 *FaultInjector
+	greylist               *Greylister
 	logger                 *slog.Logger
 	reader                 *Reader
+	session                *Session
 	writer                 *Writer
 	xssService             sanitizer.IXSSServiceProvider
 }
 
-// NewRcptCommandExecutor creates a new struct.
+// NewRcptCommandExecutor creates a new struct. Recipients may be refused on purpose by the fault injector, and by the
+// greylister when it is not nil.
 func NewRcptCommandExecutor(
 	logger *slog.Logger,
 	reader *Reader,
 	writer *Writer,
+	session *Session,
 	emailValidationService mailslurper.EmailValidationProvider,
 	xssService sanitizer.IXSSServiceProvider,
+	faults *FaultInjector,
+	greylist *Greylister,
 ) *RcptCommandExecutor {
 	return &RcptCommandExecutor{
 		emailValidationService: emailValidationService,
+		faults:                 faults,
+		greylist:               greylist,
 		logger:                 logger,
 		reader:                 reader,
+		session:                session,
 		writer:                 writer,
 		xssService:             xssService,
 	}
 }
 
-// Process handles the RCPT TO command. This command tells us who the recipient is.
+// Process handles the RCPT TO command. This command tells us who the recipient is. The address is stored exactly as the
+// client sent it, and may only contain UTF-8 when SMTPUTF8 was given with MAIL FROM.
 func (e *RcptCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
 	var (
-		to           string
-		toComponents *mail.Address
-		err          error
+		to  string
+		err error
 	)
 
 	if err = IsValidCommand(streamInput, "RCPT TO"); err != nil {
@@ -56,18 +66,38 @@ func (e *RcptCommandExecutor) Process(streamInput string, mailItem *model.MailIt
 		return err
 	}
 
-	if toComponents, err = e.emailValidationService.GetEmailComponents(to); err != nil {
-		return mailslurper.InvalidEmail(to)
+	to, parameters := SplitPathAndParameters(to)
+	for keyword := range parameters {
+		return UnsupportedParameter(keyword)
 	}
 
-	to = e.xssService.SanitizeString(toComponents.Address)
-
 	if !e.emailValidationService.IsValidEmail(to) {
 		return mailslurper.InvalidEmail(to)
 	}
 
+	if !e.session.UTF8 && !IsASCII(to) {
+		return NonASCIIAddress()
+	}
+
+	to = UnwrapPath(to)
+
+	if reply := e.faults.Recipient(to); reply != nil {
+		e.logger.Debug("Recipient refused by fault injection", "address", to, "reply", reply.String())
+
+		return reply
+	}
+
+	if e.greylist != nil {
+		clientIP, _, _ := net.SplitHostPort(e.reader.Connection.RemoteAddr().String())
+
+		if reply := e.greylist.Check(clientIP, mailItem.FromAddress, to); reply != nil {
+			e.logger.Debug("Recipient greylisted", "client", clientIP, "sender", mailItem.FromAddress, "address", to)
+
+			return reply
+		}
+	}
+
 	mailItem.ToAddresses = append(mailItem.ToAddresses, to)
-	e.writer.SendOkResponse()
 
-	return nil
+	return e.writer.SendOkResponse()
 }
@@ -5,84 +5,171 @@
 package smtp
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
-	"strings"
 	"time"
 )
 
-// Reader is a simple object for reading commands and responses from a connected TCP client.
+// Reader is a buffered, line oriented reader for commands and message data sent by a connected TCP client. Lines may
+// arrive split across any number of TCP segments, or several lines may arrive in one.
 type Reader struct {
 	Connection net.Conn
 
+	buffer *bufio.Reader
 	logger *slog.Logger
 	chStop chan struct{}
 }
 
-// Read reads the raw data from the socket connection to our client. This will read on the socket until there is nothing
-// left to read and an error is generated. This method blocks the socket for the number of milliseconds defined in
-// CONN_TIMEOUT_MILLISECONDS. It then records what has been read in that time, then blocks again until there is nothing
-// left on the socket to read. The final value is stored and returned as a string.
+// NewReader creates a new reader for the connection. Reading stops once chStop is closed.
+func NewReader(connection net.Conn, chStop chan struct{}, logger *slog.Logger) *Reader {
+	return &Reader{
+		Connection: connection,
+		buffer:     bufio.NewReaderSize(connection, RECEIVE_BUFFER_LEN),
+		logger:     logger,
+		chStop:     chStop,
+	}
+}
+
+// SetConnection switches the reader over to a new connection, such as after a STARTTLS upgrade. Anything buffered from
+// the old connection is discarded so that it cannot be mistaken for data sent over the new one (RFC 3207, section 6).
+func (r *Reader) SetConnection(connection net.Conn) {
+	r.Connection = connection
+	r.buffer.Reset(connection)
+}
+
+// Read reads a single command line, including the line ending, from the connection. Each read blocks for up to
+// CONNECTION_TIMEOUT_MINUTES. A line longer than MAX_COMMAND_LINE_LEN is thrown away and ErrLineTooLong is returned.
+// ErrReaderStopped is returned once the server is stopping.
 func (r *Reader) Read() (string, error) {
-	var raw bytes.Buffer
-	var bytesRead int
-	var err error
-
-	bytesRead = 1
-
-	for bytesRead > 0 {
-		select {
-		case <-r.chStop:
-			return "", nil
-
-		default:
-			if err = r.Connection.SetReadDeadline(time.Now().Add(time.Minute * CONNECTION_TIMEOUT_MINUTES)); err != nil {
-				return raw.String(), nil
-			}
-
-			buffer := make([]byte, RECEIVE_BUFFER_LEN)
-			bytesRead, err = r.Connection.Read(buffer)
-
-			if err != nil {
-				return raw.String(), err
-			}
-
-			if bytesRead > 0 {
-				raw.WriteString(string(buffer[:bytesRead]))
-				if strings.HasSuffix(raw.String(), "\r\n") {
-					return raw.String(), nil
-				}
-			}
-		}
+	select {
+	case <-r.chStop:
+		return "", ErrReaderStopped
+	default:
 	}
 
-	return raw.String(), nil
+	line, err := r.readLine(MAX_COMMAND_LINE_LEN)
+
+	return string(line), err
+}
+
+// IsTLS returns true if the connection being read from is encrypted, either through an implicit TLS listener or after
+// a STARTTLS upgrade.
+func (r *Reader) IsTLS() bool {
+	_, ok := r.Connection.(*tls.Conn)
+
+	return ok
 }
 
-// ReadDataBlock is used by the SMTP DATA command. It will read data from the connection until the terminator is sent.
-func (r *Reader) ReadDataBlock() (string, error) {
+// ReadDataBlock is used by the SMTP DATA command. It reads message lines until the terminating line holding a single
+// dot, removing the leading dot that clients add to any line starting with one (RFC 5321, section 4.5.2). The result
+// ends with the line ending of the last message line.
+//
+// When maxSize is greater than zero and the message grows beyond it, the rest of the message is read and thrown away so
+// the connection stays in sync, and ErrMessageTooLarge is returned once the terminator arrives.
+func (r *Reader) ReadDataBlock(maxSize int64) (string, error) {
 	var dataBuffer bytes.Buffer
 
+	exceeded := false
+
 	for {
-		dataResponse, err := r.Read()
+		line, err := r.readLine(0)
 		if err != nil {
 			r.logger.Error("Error reading in DATA block", "error", err)
 
 			return dataBuffer.String(), fmt.Errorf("Error reading in DATA block: %w", err)
 		}
 
-		dataBuffer.WriteString(dataResponse)
-		terminatorPos := strings.Index(dataBuffer.String(), SMTP_DATA_TERMINATOR)
-
-		if terminatorPos > -1 {
+		if isDataTerminator(line) {
 			break
 		}
+
+		if line[0] == '.' {
+			line = line[1:]
+		}
+
+		if exceeded {
+			continue
+		}
+
+		if maxSize > 0 && int64(dataBuffer.Len()+len(line)) > maxSize {
+			exceeded = true
+			dataBuffer.Reset()
+
+			continue
+		}
+
+		dataBuffer.Write(line)
+	}
+
+	if exceeded {
+		return "", ErrMessageTooLarge
+	}
+
+	return dataBuffer.String(), nil
+}
+
+// ReadChunk copies exactly size bytes of BDAT chunk data to the destination. Chunk data is sent as is, without dot
+// stuffing or a terminator (RFC 3030, section 2).
+func (r *Reader) ReadChunk(size int64, destination io.Writer) error {
+	if err := r.Connection.SetReadDeadline(time.Now().Add(time.Minute * CONNECTION_TIMEOUT_MINUTES)); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(destination, r.buffer, size); err != nil {
+		r.logger.Error("Error reading BDAT chunk", "error", err)
+
+		return fmt.Errorf("Error reading BDAT chunk: %w", err)
 	}
 
-	result := dataBuffer.String()
-	result = result[:len(result)-3]
+	return nil
+}
+
+// readLine reads up to and including the next line feed. A limit greater than zero caps the length of the line, in
+// which case the rest of an overlong line is consumed and ErrLineTooLong returned. The returned slice may refer to the
+// internal buffer and is only valid until the next read.
+func (r *Reader) readLine(limit int) ([]byte, error) {
+	var line []byte
+
+	if err := r.Connection.SetReadDeadline(time.Now().Add(time.Minute * CONNECTION_TIMEOUT_MINUTES)); err != nil {
+		return nil, err
+	}
+
+	tooLong := false
+
+	for {
+		fragment, err := r.buffer.ReadSlice('\n')
+
+		// most lines fit in the buffer and need no copy
+		if line == nil && !tooLong && err == nil && (limit <= 0 || len(fragment) <= limit) {
+			return fragment, nil
+		}
+
+		if !tooLong {
+			line = append(line, fragment...)
+		}
+
+		if limit > 0 && len(line) > limit {
+			tooLong = true
+			line = nil
+		}
+
+		switch {
+		case err == nil && tooLong:
+			return nil, ErrLineTooLong
+		case err == nil:
+			return line, nil
+		case !errors.Is(err, bufio.ErrBufferFull):
+			return line, err
+		}
+	}
+}
 
-	return result, nil
+func isDataTerminator(line []byte) bool {
+	return bytes.Equal(line, []byte("."+SMTP_CRLF)) || bytes.Equal(line, []byte(".\n"))
 }
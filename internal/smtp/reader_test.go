@@ -0,0 +1,219 @@
+package smtp_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mailslurper/mailslurper/v2/internal/smtp"
+)
+
+func TestReader_Read(t *testing.T) {
+	t.Parallel()
+
+	reader := newTestReader(t, []string{"EH", "LO local", "host\r\nMAIL FROM:<one@example.com>\r", "\nRCPT TO:<two@example.com>\r\n"})
+
+	for _, expected := range []string{
+		"EHLO localhost\r\n",
+		"MAIL FROM:<one@example.com>\r\n",
+		"RCPT TO:<two@example.com>\r\n",
+	} {
+		line, err := reader.Read()
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, line)
+	}
+}
+
+func TestReader_Read_LineTooLong(t *testing.T) {
+	t.Parallel()
+
+	reader := newTestReader(t, []string{"NOOP " + strings.Repeat("x", smtp.MAX_COMMAND_LINE_LEN) + "\r\n", "NOOP\r\n"})
+
+	_, err := reader.Read()
+	assert.ErrorIs(t, err, smtp.ErrLineTooLong)
+
+	// the overlong line is consumed and the next one is read normally
+	line, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "NOOP\r\n", line)
+}
+
+func TestReader_Read_Stopped(t *testing.T) {
+	t.Parallel()
+
+	server, client := net.Pipe()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	chStop := make(chan struct{})
+	close(chStop)
+
+	reader := smtp.NewReader(server, chStop, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	line, err := reader.Read()
+	assert.ErrorIs(t, err, smtp.ErrReaderStopped)
+	assert.Empty(t, line)
+}
+
+func TestReader_ReadDataBlock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		segments []string
+		expected string
+	}{
+		{
+			name:     "single segment",
+			segments: []string{"Subject: test\r\n\r\nbody\r\n.\r\n"},
+			expected: "Subject: test\r\n\r\nbody\r\n",
+		},
+		{
+			name:     "terminator split across segments",
+			segments: []string{"Subject: test\r\n\r\nbody\r", "\n", ".", "\r", "\n"},
+			expected: "Subject: test\r\n\r\nbody\r\n",
+		},
+		{
+			name:     "dot unstuffing",
+			segments: []string{"Subject: test\r\n\r\n..\r\n...leading dots\r\nno.dot.\r\n.\r\n"},
+			expected: "Subject: test\r\n\r\n.\r\n..leading dots\r\nno.dot.\r\n",
+		},
+		{
+			name:     "dot inside a line is not a terminator",
+			segments: []string{"Subject: test\r\n\r\nfirst\r\n .\r\n.x\r\n.\r\n"},
+			expected: "Subject: test\r\n\r\nfirst\r\n .\r\nx\r\n",
+		},
+		{
+			name:     "empty message",
+			segments: []string{".\r\n"},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		reader := newTestReader(t, append(test.segments, "QUIT\r\n"))
+
+		data, err := reader.ReadDataBlock(0)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, data, test.name)
+
+		// nothing after the terminator is consumed
+		line, err := reader.Read()
+		require.NoError(t, err, test.name)
+		assert.Equal(t, "QUIT\r\n", line, test.name)
+	}
+}
+
+func TestReader_ReadDataBlock_MaxSize(t *testing.T) {
+	t.Parallel()
+
+	reader := newTestReader(t, []string{"Subject: test\r\n\r\n", strings.Repeat("0123456789\r\n", 100), ".\r\n", "QUIT\r\n"})
+
+	_, err := reader.ReadDataBlock(512)
+	assert.ErrorIs(t, err, smtp.ErrMessageTooLarge)
+
+	line, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "QUIT\r\n", line)
+}
+
+func BenchmarkReader_ReadDataBlock(b *testing.B) {
+	for _, size := range []int{1 << 20, 5 << 20, 20 << 20} {
+		message := newAttachmentMessage(size)
+
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(len(message)))
+			b.ReportAllocs()
+
+			for b.Loop() {
+				server, client := net.Pipe()
+				reader := smtp.NewReader(server, make(chan struct{}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+				go func() {
+					// write in TCP sized chunks so lines are split across reads
+					for chunk := range chunks(message, 1460) {
+						_, _ = client.Write(chunk)
+					}
+				}()
+
+				if _, err := reader.ReadDataBlock(0); err != nil {
+					b.Fatal(err)
+				}
+
+				_ = server.Close()
+				_ = client.Close()
+			}
+		})
+	}
+}
+
+func newTestReader(t *testing.T, segments []string) *smtp.Reader {
+	t.Helper()
+
+	server, client := net.Pipe()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	go func() {
+		for _, segment := range segments {
+			if _, err := client.Write([]byte(segment)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return smtp.NewReader(server, make(chan struct{}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// newAttachmentMessage builds a message carrying a base64 encoded attachment of the given size, dot-stuffed and
+// terminated as it would be sent by a client.
+func newAttachmentMessage(size int) []byte {
+	var message bytes.Buffer
+
+	attachment := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(1)).Read(attachment)
+
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+
+	message.WriteString("Subject: attachment\r\nContent-Type: multipart/mixed; boundary=\"boundary\"\r\n\r\n")
+	message.WriteString("--boundary\r\nContent-Type: text/plain\r\n\r\n.A dot-stuffed body line\r\n")
+	message.WriteString("--boundary\r\nContent-Type: application/octet-stream\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+
+	for len(encoded) > 76 {
+		message.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+
+	message.WriteString(encoded + "\r\n--boundary--\r\n.\r\n")
+
+	return message.Bytes()
+}
+
+func chunks(data []byte, size int) func(func([]byte) bool) {
+	return func(yield func([]byte) bool) {
+		for len(data) > 0 {
+			n := min(size, len(data))
+			if !yield(data[:n]) {
+				return
+			}
+
+			data = data[n:]
+		}
+	}
+}
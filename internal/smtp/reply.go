@@ -0,0 +1,92 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
+)
+
+// ReplyForError translates an error returned by a command executor into the reply sent to the client. The second
+// return value is false for errors that cannot be answered, such as a broken connection, which end the session.
+func ReplyForError(command Command, err error) (*ReplyError, bool) {
+	var (
+		replyError           *ReplyError
+		badSequence          *BadSequenceError
+		invalidEmail         *mailslurper.InvalidEmailError
+		unsupportedParam     *UnsupportedParameterError
+		invalidCommand       *InvalidCommandError
+		invalidCommandFormat *InvalidCommandFormatError
+	)
+
+	switch {
+	case errors.As(err, &replyError):
+		return replyError, true
+
+	case errors.As(err, &badSequence):
+		return Reply(SMTP_REPLY_BAD_SEQUENCE, "5.5.1", "Bad sequence of commands"), true
+
+	case errors.As(err, &invalidEmail):
+		if command == MAIL {
+			return Reply(SMTP_REPLY_SYNTAX_ERROR, "5.1.7", "Bad sender address syntax"), true
+		}
+
+		return Reply(SMTP_REPLY_SYNTAX_ERROR, "5.1.3", "Bad recipient address syntax"), true
+
+	case errors.As(err, &unsupportedParam):
+		return Reply(SMTP_REPLY_PARAMETER_NOT_IMPLEMENTED, "5.5.4", "Parameter not recognized or not implemented"), true
+
+	case errors.As(err, &invalidCommand), errors.As(err, &invalidCommandFormat):
+		return Reply(SMTP_REPLY_SYNTAX_ERROR, "5.5.4", "Syntax error in parameters or arguments"), true
+	}
+
+	return nil, false
+}
+
+// UnrecognizedCommand returns the reply for input that does not match a supported command.
+func UnrecognizedCommand(streamInput string) *ReplyError {
+	lowercaseStreamInput := strings.ToLower(strings.TrimSpace(streamInput))
+
+	for _, command := range UnimplementedCommands {
+		if lowercaseStreamInput == command || strings.HasPrefix(lowercaseStreamInput, command+" ") {
+			return CommandNotImplemented()
+		}
+	}
+
+	return Reply(SMTP_REPLY_COMMAND_UNRECOGNIZED, "5.5.2", "Syntax error, command unrecognized")
+}
+
+// CommandNotImplemented returns the reply for a command that is understood but not available, either because it is not
+// supported at all or because the extension it belongs to is disabled.
+func CommandNotImplemented() *ReplyError {
+	return Reply(SMTP_REPLY_COMMAND_NOT_IMPLEMENTED, "5.5.1", "Command not implemented")
+}
+
+// LineTooLong returns the reply for a command line longer than the reader accepts (RFC 5321, section 4.5.3.1.4).
+func LineTooLong() *ReplyError {
+	return Reply(SMTP_REPLY_COMMAND_UNRECOGNIZED, "5.5.6", "Line too long")
+}
+
+// Greylisted returns the reply for a delivery attempt refused by greylisting. Well behaved clients retry later.
+func Greylisted() *ReplyError {
+	return Reply(SMTP_REPLY_LOCAL_ERROR, "4.7.1", "Greylisted, please try again later")
+}
+
+// MessageTooLarge returns the reply for a message that exceeds the maximum message size.
+func MessageTooLarge() *ReplyError {
+	return Reply(SMTP_REPLY_EXCEEDED_STORAGE, "5.3.4", "Message size exceeds fixed maximum message size")
+}
+
+// NonASCIIAddress returns the reply for an address containing UTF-8 in a transaction started without the SMTPUTF8
+// parameter (RFC 6531, section 3.5).
+func NonASCIIAddress() *ReplyError {
+	return Reply(SMTP_REPLY_MAILBOX_NAME_NOT_ALLOWED, "5.6.7", "Non-ASCII addresses require the SMTPUTF8 parameter")
+}
+
+// TransactionFailed returns the reply for a message that was received but could not be accepted.
+func TransactionFailed() *ReplyError {
+	return Reply(SMTP_REPLY_TRANSACTION_FAILED, "5.6.0", "Transaction failed")
+}
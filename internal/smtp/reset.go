@@ -4,7 +4,6 @@
 package smtp
 
 import (
-	"fmt"
 	"log/slog"
 	"strings"
 
@@ -28,7 +27,7 @@ func NewResetCommandExecutor(logger *slog.Logger, writer *Writer) *ResetCommandE
 // Process handles the RSET command.
 func (e *ResetCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
 	if strings.ToLower(streamInput) != "rset" {
-		return fmt.Errorf("Invalid RSET command")
+		return InvalidCommandFormat("RSET")
 	}
 
 	// Overwrite current mail object with an empty one
@@ -5,15 +5,19 @@
 package smtp
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net"
 	"time"
 
 	"github.com/adampresley/webframework/sanitizer"
+	"github.com/pkg/errors"
 
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
 	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
 	"github.com/mailslurper/mailslurper/v2/internal/model"
+	"github.com/mailslurper/mailslurper/v2/pkg/cache"
 )
 
 // ServerPool represents a pool of SMTP workers. This will manage how many workers may respond to SMTP client requests
@@ -29,28 +33,55 @@ func (pool *ServerPool) JoinQueue(worker *Worker) {
 }
 
 // NewServerPool creates a new server pool with a maximum number of SMTP workers. An array of workers is initialized
-// with an ID and an initial state of SMTP_WORKER_IDLE.
-func NewServerPool(maxWorkers int, xss sanitizer.IXSSServiceProvider, logger *slog.Logger) *ServerPool {
+// with an ID and an initial state of SMTP_WORKER_IDLE. When STARTTLS is enabled the certificate pair is loaded here so
+// that each worker is able to upgrade its connection. All workers share the fault injector, and the greylisting state
+// kept in memory when greylisting is enabled.
+func NewServerPool(
+	config *slurperio.Config,
+	xss sanitizer.IXSSServiceProvider,
+	faults *FaultInjector,
+	logger *slog.Logger,
+) (*ServerPool, error) {
+	var tlsConfig *tls.Config
+	var greylist *Greylister
+
 	emailValidationService := mailslurper.NewEmailValidationService()
 
+	if config.SMTP.StartTLS.IsEnabled() {
+		certificate, err := tls.LoadX509KeyPair(config.SMTP.CertFile, config.SMTP.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error loading X509 certificate key pair while setting up STARTTLS")
+		}
+
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+
+	if config.SMTP.Greylist.Enabled {
+		greylist = NewGreylister(cache.NewMemoryCacheService(), config.SMTP.Greylist)
+	}
+
 	pool := &ServerPool{
-		pool:   make(chan *Worker, maxWorkers),
+		pool:   make(chan *Worker, config.MaxWorkers),
 		logger: logger,
 	}
 
-	for idx := range maxWorkers {
+	for idx := range config.MaxWorkers {
 		pool.JoinQueue(NewWorker(
 			idx+1,
 			pool,
 			emailValidationService,
 			xss,
+			config.SMTP,
+			tlsConfig,
+			faults,
+			greylist,
 			logger.With("who", fmt.Sprintf("SMTP Worker %d", idx+1)),
 		))
 	}
 
-	logger.Info("Worker pool configured", "workers", maxWorkers)
+	logger.Info("Worker pool configured", "workers", config.MaxWorkers)
 
-	return pool
+	return pool, nil
 }
 
 // NextWorker retrieves the next available worker from the queue.
@@ -65,11 +96,7 @@ func (pool *ServerPool) NextWorker(
 		worker.Prepare(
 			connection,
 			receiver,
-			&Reader{
-				Connection: connection,
-				logger:     pool.logger.With("who", fmt.Sprintf("SMTP Reader %d", worker.WorkerID)),
-				chStop:     chStop,
-			},
+			NewReader(connection, chStop, pool.logger.With("who", fmt.Sprintf("SMTP Reader %d", worker.WorkerID))),
 			&Writer{
 				Connection: connection,
 				logger:     pool.logger.With("who", fmt.Sprintf("SMTP Writer %d", worker.WorkerID)),
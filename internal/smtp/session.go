@@ -0,0 +1,73 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import "bytes"
+
+// Session holds the state of a single client connection that outlives an individual mail transaction. A new session
+// is started for every connection and again after a STARTTLS upgrade.
+type Session struct {
+	// State is where the client is in the command sequence.
+	State SessionState
+	// UTF8 is true when the current mail transaction was started with the SMTPUTF8 parameter, allowing UTF-8 in
+	// envelope addresses. It is cleared when the transaction ends.
+	UTF8 bool
+	// Chunks collects the message data sent with BDAT until the last chunk arrives. It is discarded whenever the
+	// session leaves the BDAT state.
+	Chunks bytes.Buffer
+
+	// AuthenticatedUser is the user name accepted by the AUTH command, if any.
+	AuthenticatedUser string
+	// HeloDomain is the domain or address literal the client identified itself with in HELO or EHLO.
+	HeloDomain string
+}
+
+// NewSession creates a new, unauthenticated session.
+func NewSession() *Session {
+	return &Session{}
+}
+
+// IsAuthenticated returns true if the client has successfully completed the AUTH command.
+func (s *Session) IsAuthenticated() bool {
+	return s.AuthenticatedUser != ""
+}
+
+// Reset discards everything known about the client.
+func (s *Session) Reset() {
+	*s = Session{}
+}
+
+// Accept returns a BadSequenceError if the command may not be sent in the current state.
+func (s *Session) Accept(command Command) error {
+	_, err := NextState(s.State, command)
+
+	return err
+}
+
+// Advance moves the session to the next state once the command has completed successfully. Commands that are not
+// allowed in the current state leave it unchanged.
+func (s *Session) Advance(command Command) {
+	if state, err := NextState(s.State, command); err == nil {
+		s.setState(state)
+	}
+}
+
+// EndTransaction abandons the mail transaction in progress, if any, such as after a message was rejected.
+func (s *Session) EndTransaction() {
+	if s.State != SESSION_STATE_CONNECTED {
+		s.setState(SESSION_STATE_READY)
+	}
+}
+
+func (s *Session) setState(state SessionState) {
+	s.State = state
+
+	if state != SESSION_STATE_BDAT {
+		s.Chunks.Reset()
+	}
+
+	if state == SESSION_STATE_CONNECTED || state == SESSION_STATE_READY {
+		s.UTF8 = false
+	}
+}
@@ -0,0 +1,80 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mailslurper/mailslurper/v2/internal/model"
+)
+
+// StartTLSCommandExecutor process the command STARTTLS.
+type StartTLSCommandExecutor struct {
+	logger    *slog.Logger
+	reader    *Reader
+	writer    *Writer
+	session   *Session
+	tlsConfig *tls.Config
+}
+
+// NewStartTLSCommandExecutor creates a new struct.
+func NewStartTLSCommandExecutor(
+	logger *slog.Logger,
+	reader *Reader,
+	writer *Writer,
+	session *Session,
+	tlsConfig *tls.Config,
+) *StartTLSCommandExecutor {
+	return &StartTLSCommandExecutor{
+		logger:    logger,
+		reader:    reader,
+		writer:    writer,
+		session:   session,
+		tlsConfig: tlsConfig,
+	}
+}
+
+// Process handles the STARTTLS command. The client is told to begin the TLS handshake, after which the reader and
+// writer are switched over to the encrypted connection. Per RFC 3207 all knowledge obtained from the client before the
+// handshake is discarded, so the session and mail item are reset.
+func (e *StartTLSCommandExecutor) Process(streamInput string, mailItem *model.MailItem) error {
+	if e.tlsConfig == nil {
+		return CommandNotImplemented()
+	}
+
+	if strings.ToLower(streamInput) != "starttls" {
+		return InvalidCommandFormat("STARTTLS")
+	}
+
+	if e.reader.IsTLS() {
+		return BadSequence("STARTTLS", "while TLS is already active")
+	}
+
+	if err := e.writer.SendResponse(SMTP_STARTTLS_READY_MESSAGE); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*TLS_HANDSHAKE_TIMEOUT_SECONDS)
+	defer cancel()
+
+	connection := tls.Server(e.reader.Connection, e.tlsConfig)
+	if err := connection.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("Problem performing TLS handshake: %w", err)
+	}
+
+	e.reader.SetConnection(connection)
+	e.writer.Connection = connection
+
+	e.logger.Debug("Connection upgraded to TLS", "version", tls.VersionName(connection.ConnectionState().Version))
+
+	e.session.Reset()
+	*mailItem = *model.NewEmptyMailItem(e.logger)
+
+	return nil
+}
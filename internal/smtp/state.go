@@ -0,0 +1,118 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package smtp
+
+// SessionState is a step in the SMTP command sequence (RFC 5321, section 4.1.4). A session starts out CONNECTED, moves
+// to READY once the client identifies itself, and then goes through MAIL and RCPT for every mail transaction. DATA
+// ends the transaction and returns the session to READY. A message may instead be sent in chunks with BDAT
+// (RFC 3030), in which case the session stays in BDAT until the last chunk arrives.
+type SessionState int
+
+const (
+	SESSION_STATE_CONNECTED SessionState = iota
+	SESSION_STATE_READY
+	SESSION_STATE_MAIL
+	SESSION_STATE_RCPT
+	SESSION_STATE_BDAT
+)
+
+// SessionStatesToStrings is a friendly string representation of session states. Useful in error reporting.
+var SessionStatesToStrings = map[SessionState]string{
+	SESSION_STATE_CONNECTED: "CONNECTED",
+	SESSION_STATE_READY:     "READY",
+	SESSION_STATE_MAIL:      "MAIL",
+	SESSION_STATE_RCPT:      "RCPT",
+	SESSION_STATE_BDAT:      "BDAT",
+}
+
+// String returns the string representation of a session state.
+func (state SessionState) String() string {
+	return SessionStatesToStrings[state]
+}
+
+// NextState returns the state a session moves to when the command completes successfully in the given state. A
+// BadSequenceError is returned if the command is not allowed in that state.
+//
+//   - HELO/EHLO may be sent at any time and abandons any transaction in progress.
+//   - MAIL starts a transaction and needs a prior HELO/EHLO and no transaction in progress.
+//   - RCPT needs a sender and may be repeated.
+//   - DATA needs at least one recipient and completes the transaction.
+//   - BDAT needs at least one recipient and may be repeated. The last chunk completes the transaction. DATA may not
+//     be mixed with BDAT.
+//   - RSET abandons the transaction, but does not stand in for HELO/EHLO.
+//   - STARTTLS and AUTH are not allowed during a transaction, and AUTH also needs a prior HELO/EHLO.
+//   - NOOP and QUIT are allowed at any time.
+func NextState(state SessionState, command Command) (SessionState, error) {
+	inTransaction := state == SESSION_STATE_MAIL || state == SESSION_STATE_RCPT || state == SESSION_STATE_BDAT
+
+	switch command {
+	case HELO:
+		return SESSION_STATE_READY, nil
+
+	case MAIL:
+		switch state {
+		case SESSION_STATE_CONNECTED:
+			return state, BadSequence(command.String(), "before HELO/EHLO")
+		case SESSION_STATE_READY:
+			return SESSION_STATE_MAIL, nil
+		default:
+			return state, BadSequence(command.String(), "while a mail transaction is in progress")
+		}
+
+	case RCPT:
+		switch state {
+		case SESSION_STATE_MAIL, SESSION_STATE_RCPT:
+			return SESSION_STATE_RCPT, nil
+		case SESSION_STATE_BDAT:
+			return state, BadSequence(command.String(), "after BDAT")
+		default:
+			return state, BadSequence(command.String(), "before MAIL FROM")
+		}
+
+	case DATA:
+		if state != SESSION_STATE_RCPT {
+			return state, BadSequence(command.String(), "without a sender and at least one recipient")
+		}
+
+		return SESSION_STATE_READY, nil
+
+	case BDAT, BDAT_LAST:
+		if state != SESSION_STATE_RCPT && state != SESSION_STATE_BDAT {
+			return state, BadSequence(command.String(), "without a sender and at least one recipient")
+		}
+
+		if command == BDAT_LAST {
+			return SESSION_STATE_READY, nil
+		}
+
+		return SESSION_STATE_BDAT, nil
+
+	case RSET:
+		if state == SESSION_STATE_CONNECTED {
+			return state, nil
+		}
+
+		return SESSION_STATE_READY, nil
+
+	case STARTTLS:
+		if inTransaction {
+			return state, BadSequence(command.String(), "while a mail transaction is in progress")
+		}
+
+		return SESSION_STATE_CONNECTED, nil
+
+	case AUTH:
+		switch state {
+		case SESSION_STATE_CONNECTED:
+			return state, BadSequence(command.String(), "before HELO/EHLO")
+		case SESSION_STATE_READY:
+			return state, nil
+		default:
+			return state, BadSequence(command.String(), "while a mail transaction is in progress")
+		}
+
+	default:
+		return state, nil
+	}
+}
@@ -0,0 +1,128 @@
+package smtp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailslurper/mailslurper/v2/internal/smtp"
+)
+
+func TestNextState(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		state    smtp.SessionState
+		command  smtp.Command
+		expected smtp.SessionState
+		valid    bool
+	}{
+		{name: "HELO after greeting", state: smtp.SESSION_STATE_CONNECTED, command: smtp.HELO, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "HELO during transaction", state: smtp.SESSION_STATE_RCPT, command: smtp.HELO, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "MAIL before HELO", state: smtp.SESSION_STATE_CONNECTED, command: smtp.MAIL, expected: smtp.SESSION_STATE_CONNECTED},
+		{name: "MAIL after HELO", state: smtp.SESSION_STATE_READY, command: smtp.MAIL, expected: smtp.SESSION_STATE_MAIL, valid: true},
+		{name: "nested MAIL", state: smtp.SESSION_STATE_MAIL, command: smtp.MAIL, expected: smtp.SESSION_STATE_MAIL},
+		{name: "RCPT without MAIL", state: smtp.SESSION_STATE_READY, command: smtp.RCPT, expected: smtp.SESSION_STATE_READY},
+		{name: "RCPT after MAIL", state: smtp.SESSION_STATE_MAIL, command: smtp.RCPT, expected: smtp.SESSION_STATE_RCPT, valid: true},
+		{name: "RCPT repeated", state: smtp.SESSION_STATE_RCPT, command: smtp.RCPT, expected: smtp.SESSION_STATE_RCPT, valid: true},
+		{name: "DATA without RCPT", state: smtp.SESSION_STATE_MAIL, command: smtp.DATA, expected: smtp.SESSION_STATE_MAIL},
+		{name: "DATA before MAIL", state: smtp.SESSION_STATE_READY, command: smtp.DATA, expected: smtp.SESSION_STATE_READY},
+		{name: "DATA after RCPT", state: smtp.SESSION_STATE_RCPT, command: smtp.DATA, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "BDAT before RCPT", state: smtp.SESSION_STATE_MAIL, command: smtp.BDAT, expected: smtp.SESSION_STATE_MAIL},
+		{name: "BDAT after RCPT", state: smtp.SESSION_STATE_RCPT, command: smtp.BDAT, expected: smtp.SESSION_STATE_BDAT, valid: true},
+		{name: "BDAT repeated", state: smtp.SESSION_STATE_BDAT, command: smtp.BDAT, expected: smtp.SESSION_STATE_BDAT, valid: true},
+		{name: "BDAT LAST after BDAT", state: smtp.SESSION_STATE_BDAT, command: smtp.BDAT_LAST, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "BDAT LAST after RCPT", state: smtp.SESSION_STATE_RCPT, command: smtp.BDAT_LAST, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "DATA after BDAT", state: smtp.SESSION_STATE_BDAT, command: smtp.DATA, expected: smtp.SESSION_STATE_BDAT},
+		{name: "RCPT after BDAT", state: smtp.SESSION_STATE_BDAT, command: smtp.RCPT, expected: smtp.SESSION_STATE_BDAT},
+		{name: "RSET before HELO", state: smtp.SESSION_STATE_CONNECTED, command: smtp.RSET, expected: smtp.SESSION_STATE_CONNECTED, valid: true},
+		{name: "RSET during transaction", state: smtp.SESSION_STATE_RCPT, command: smtp.RSET, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "STARTTLS after HELO", state: smtp.SESSION_STATE_READY, command: smtp.STARTTLS, expected: smtp.SESSION_STATE_CONNECTED, valid: true},
+		{name: "STARTTLS during transaction", state: smtp.SESSION_STATE_MAIL, command: smtp.STARTTLS, expected: smtp.SESSION_STATE_MAIL},
+		{name: "AUTH before HELO", state: smtp.SESSION_STATE_CONNECTED, command: smtp.AUTH, expected: smtp.SESSION_STATE_CONNECTED},
+		{name: "AUTH after HELO", state: smtp.SESSION_STATE_READY, command: smtp.AUTH, expected: smtp.SESSION_STATE_READY, valid: true},
+		{name: "AUTH during transaction", state: smtp.SESSION_STATE_RCPT, command: smtp.AUTH, expected: smtp.SESSION_STATE_RCPT},
+		{name: "NOOP during transaction", state: smtp.SESSION_STATE_MAIL, command: smtp.NOOP, expected: smtp.SESSION_STATE_MAIL, valid: true},
+	}
+
+	for _, test := range tests {
+		state, err := smtp.NextState(test.state, test.command)
+
+		assert.Equal(t, test.expected, state, test.name)
+
+		if test.valid {
+			assert.NoError(t, err, test.name)
+		} else {
+			var badSequence *smtp.BadSequenceError
+			assert.ErrorAs(t, err, &badSequence, test.name)
+		}
+	}
+}
+
+func TestSession_Transaction(t *testing.T) {
+	t.Parallel()
+
+	session := smtp.NewSession()
+
+	for _, command := range []smtp.Command{smtp.HELO, smtp.MAIL, smtp.RCPT, smtp.RCPT} {
+		assert.NoError(t, session.Accept(command), command.String())
+		session.Advance(command)
+	}
+
+	assert.Equal(t, smtp.SESSION_STATE_RCPT, session.State)
+
+	// a rejected command leaves the session where it was
+	assert.Error(t, session.Accept(smtp.MAIL))
+	session.Advance(smtp.MAIL)
+	assert.Equal(t, smtp.SESSION_STATE_RCPT, session.State)
+
+	session.Advance(smtp.RSET)
+	assert.Equal(t, smtp.SESSION_STATE_READY, session.State)
+	assert.Error(t, session.Accept(smtp.DATA))
+
+	session.Reset()
+	assert.Equal(t, smtp.SESSION_STATE_CONNECTED, session.State)
+}
+
+func TestSession_Chunks(t *testing.T) {
+	t.Parallel()
+
+	session := smtp.NewSession()
+
+	for _, command := range []smtp.Command{smtp.HELO, smtp.MAIL, smtp.RCPT, smtp.BDAT} {
+		session.Advance(command)
+	}
+
+	session.Chunks.WriteString("Subject: chunked\r\n")
+
+	session.Advance(smtp.BDAT)
+	assert.Equal(t, "Subject: chunked\r\n", session.Chunks.String(), "chunks are kept between BDAT commands")
+
+	session.EndTransaction()
+	assert.Equal(t, smtp.SESSION_STATE_READY, session.State)
+	assert.Zero(t, session.Chunks.Len(), "chunks are discarded with the transaction")
+}
+
+func TestParseBdatCommand(t *testing.T) {
+	t.Parallel()
+
+	size, last, err := smtp.ParseBdatCommand("BDAT 1024")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), size)
+	assert.False(t, last)
+
+	size, last, err = smtp.ParseBdatCommand("bdat 0 last")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	assert.True(t, last)
+
+	for _, input := range []string{"BDAT", "BDAT -1", "BDAT ten", "BDAT 10 FIRST", "BDAT 10 LAST MORE"} {
+		_, _, err = smtp.ParseBdatCommand(input)
+		assert.Error(t, err, input)
+	}
+
+	command, err := smtp.GetCommandFromString("BDAT 10 LAST")
+	assert.NoError(t, err)
+	assert.Equal(t, smtp.BDAT_LAST, command)
+}
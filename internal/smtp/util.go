@@ -6,6 +6,7 @@ package smtp
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -14,13 +15,13 @@ If there isn't a split, or a missing colon, an InvalidCommandFormatError is
 returned.
 */
 func GetCommandValue(streamInput, command, delimiter string) (string, error) {
-	split := strings.Split(streamInput, delimiter)
+	_, value, found := strings.Cut(streamInput, delimiter)
 
-	if len(split) < 2 {
+	if !found {
 		return "", InvalidCommandFormat(command)
 	}
 
-	return strings.TrimSpace(strings.Join(split[1:], "")), nil
+	return strings.TrimSpace(value), nil
 }
 
 /*
@@ -37,3 +38,59 @@ func IsValidCommand(streamInput, expectedCommand string) error {
 
 	return nil
 }
+
+/*
+SplitPathAndParameters separates the path of a MAIL FROM or RCPT TO command
+from the ESMTP parameters that may follow it, such as BODY=8BITMIME.
+Parameter keywords are upper cased. A keyword without a value maps to an
+empty string.
+*/
+func SplitPathAndParameters(value string) (string, map[string]string) {
+	var path, rest string
+
+	parameters := make(map[string]string)
+
+	if strings.HasPrefix(value, "<") && strings.Contains(value, ">") {
+		end := strings.Index(value, ">")
+		path, rest = value[:end+1], value[end+1:]
+	} else {
+		fields := strings.SplitN(value, " ", 2)
+		path = fields[0]
+
+		if len(fields) > 1 {
+			rest = fields[1]
+		}
+	}
+
+	for _, parameter := range strings.Fields(rest) {
+		keyword, parameterValue, _ := strings.Cut(parameter, "=")
+		parameters[strings.ToUpper(keyword)] = parameterValue
+	}
+
+	return path, parameters
+}
+
+/*
+UnwrapPath returns the address inside the angle brackets of a MAIL FROM or
+RCPT TO path, exactly as the client sent it.
+*/
+func UnwrapPath(path string) string {
+	if strings.HasPrefix(path, "<") && strings.HasSuffix(path, ">") {
+		return path[1 : len(path)-1]
+	}
+
+	return path
+}
+
+/*
+IsASCII returns true if the input contains only 7-bit ASCII characters.
+*/
+func IsASCII(input string) bool {
+	for idx := 0; idx < len(input); idx++ {
+		if input[idx] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
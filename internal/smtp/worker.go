@@ -5,8 +5,10 @@
 package smtp
 
 import (
+	"crypto/tls"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/jinzhu/copier"
 	"github.com/pkg/errors"
 
+	slurperio "github.com/mailslurper/mailslurper/v2/internal/io"
 	"github.com/mailslurper/mailslurper/v2/internal/mailslurper"
 	"github.com/mailslurper/mailslurper/v2/internal/model"
 )
@@ -30,23 +33,37 @@ type Worker struct {
 	Writer                 *Writer
 	XSSService             sanitizer.IXSSServiceProvider
 
+	config                 slurperio.SMTPConfig
 	connectionCloseChannel chan net.Conn
 	chStop                 chan struct{}
+	faults                 *FaultInjector
+	greylist               *Greylister
 	pool                   *ServerPool
 	logger                 *slog.Logger
+	session                *Session
+	tlsConfig              *tls.Config
 }
 
 type smtpCommand struct {
 	Command     Command
 	StreamInput string
+	// Reply is sent in place of running a command that could not be read.
+	Reply *ReplyError
 }
 
-// NewWorker creates a new SMTP worker. An SMTP worker is responsible for parsing and working with SMTP mail data.
+// NewWorker creates a new SMTP worker. An SMTP worker is responsible for parsing and working with SMTP mail data. The
+// config determines which protocol extensions are offered to clients, and a non-nil TLS config enables STARTTLS. The
+// fault injector and greylister are shared with every other worker in the pool, and a nil greylister disables
+// greylisting.
 func NewWorker(
 	workerID int,
 	pool *ServerPool,
 	emailValidationService mailslurper.EmailValidationProvider,
 	xssService sanitizer.IXSSServiceProvider,
+	config slurperio.SMTPConfig,
+	tlsConfig *tls.Config,
+	faults *FaultInjector,
+	greylist *Greylister,
 	logger *slog.Logger,
 ) *Worker {
 	return &Worker{
@@ -55,8 +72,12 @@ func NewWorker(
 		State:                  SMTP_WORKER_IDLE,
 		XSSService:             xssService,
 
-		pool:   pool,
-		logger: logger,
+		config:    config,
+		faults:    faults,
+		greylist:  greylist,
+		pool:      pool,
+		logger:    logger,
+		tlsConfig: tlsConfig,
 	}
 }
 
@@ -91,6 +112,7 @@ func (w *Worker) Work() {
 	var err error
 
 	w.Writer.SayHello()
+	w.session = NewSession()
 	mailItem := model.NewEmptyMailItem(w.logger)
 
 	quitChannel := make(chan bool, 2)
@@ -100,7 +122,11 @@ func (w *Worker) Work() {
 	commandDoneChannel := make(chan error)
 
 	/*
-	 * This goroutine is the command processor
+	 * This goroutine is the command processor. It reads one line at a time
+	 * and waits for each command to finish before reading the next, so a
+	 * batch of pipelined commands (RFC 2920) is answered in order, and
+	 * commands such as DATA and AUTH can read their own input from the
+	 * same reader.
 	 */
 	go func() {
 		var streamInput string
@@ -116,6 +142,19 @@ func (w *Worker) Work() {
 
 			default:
 				if streamInput, err = w.Reader.Read(); err != nil {
+					// the server is stopping, and says goodbye to the client itself
+					if errors.Is(err, ErrReaderStopped) {
+						return
+					}
+
+					if errors.Is(err, ErrLineTooLong) {
+						w.logger.With("connection", w.Connection.RemoteAddr().String()).Debug("Command line too long")
+
+						commandChannel <- smtpCommand{Command: NONE, Reply: LineTooLong()}
+						<-commandDoneChannel
+						continue
+					}
+
 					if networkError, ok = err.(net.Error); ok {
 						if networkError.Timeout() {
 							w.logger.With("connection", w.Connection.RemoteAddr().String()).Info("Connection inactivity timeout")
@@ -127,13 +166,11 @@ func (w *Worker) Work() {
 					}
 
 					workerErrorChannel <- err
-					break
+					return
 				}
 
 				if command, err = GetCommandFromString(streamInput); err != nil {
-					w.logger.With("input", streamInput).Error("Problem finding command from input", "error", err)
-					workerErrorChannel <- errors.Wrapf(err, "Problem finding command from input %s", streamInput)
-					break
+					w.logger.With("input", streamInput).Debug("Problem finding command from input", "error", err)
 				}
 
 				if command == QUIT {
@@ -146,7 +183,10 @@ func (w *Worker) Work() {
 				err = <-commandDoneChannel
 
 				if err != nil {
-					w.logger.Error("Error executing command", "error", err)
+					if !errors.Is(err, ErrConnectionDropped) && !errors.Is(err, ErrReaderStopped) {
+						w.logger.Error("Error executing command", "error", err)
+					}
+
 					quitCommandChannel <- true
 				}
 			}
@@ -159,7 +199,9 @@ func (w *Worker) Work() {
 			w.State = SMTP_WORKER_DONE
 			w.Writer.SayGoodbye()
 			w.connectionCloseChannel <- w.Connection
-			break
+
+			// chStop stays closed, so carrying on would say goodbye again on every pass
+			return
 
 		case <-quitChannel:
 			w.logger.With("connection", w.Connection.RemoteAddr().String()).Info("QUIT command received")
@@ -186,20 +228,78 @@ func (w *Worker) Work() {
 				continue
 			}
 
+			w.faults.Delay()
+
+			if command.Command == NONE {
+				reply := command.Reply
+				if reply == nil {
+					reply = UnrecognizedCommand(command.StreamInput)
+				}
+
+				w.Writer.SendResponse(reply.String())
+
+				commandDoneChannel <- nil
+				continue
+			}
+
+			if command.Command == MAIL && w.config.StartTLS == slurperio.StartTLSRequired && !w.Reader.IsTLS() {
+				w.Writer.SendResponse(SMTP_TLS_REQUIRED_MESSAGE)
+
+				commandDoneChannel <- nil
+				continue
+			}
+
 			executor := w.getExecutorFromCommand(command.Command)
 			command.StreamInput = strings.TrimSpace(command.StreamInput)
 
-			if err = executor.Process(command.StreamInput, mailItem); err != nil {
-				w.logger.With("command", command.Command.String(), "input", command.StreamInput).Error("Problem executing command", "error", err)
+			if err = w.session.Accept(command.Command); err == nil {
+				err = executor.Process(command.StreamInput, mailItem)
+			} else if bdat, ok := executor.(*BdatCommandExecutor); ok {
+				// chunk data follows the command even when it is refused (RFC 3030, section 2)
+				if discardErr := bdat.Discard(command.StreamInput); discardErr != nil {
+					err = discardErr
+				}
+			}
+
+			if err != nil {
+				if reply, ok := ReplyForError(command.Command, err); ok {
+					w.logger.With("command", command.Command.String(), "input", command.StreamInput).Debug("Command rejected", "error", err)
+					w.Writer.SendResponse(reply.String())
+
+					// a message that was received but could not be accepted still ends the transaction
+					var rejected *MessageRejectedError
+					if errors.As(err, &rejected) {
+						w.session.EndTransaction()
+					}
+
+					commandDoneChannel <- nil
+					continue
+				}
+
+				// a command cut short by the server stopping ends with the goodbye sent on stopping
+				if errors.Is(err, ErrReaderStopped) {
+					commandDoneChannel <- err
+					continue
+				}
+
+				// a connection dropped on purpose is not worth an error in the log
+				if !errors.Is(err, ErrConnectionDropped) {
+					w.logger.With("command", command.Command.String(), "input", command.StreamInput).Error("Problem executing command", "error", err)
+				}
+
 				workerErrorChannel <- errors.Wrapf(err, "Problem executing command %s (stream input == '%s')", command.Command.String(), command.StreamInput)
 
 				commandDoneChannel <- err
 				continue
 			}
 
-			if command.Command == DATA {
+			w.session.Advance(command.Command)
+
+			if command.Command == DATA || command.Command == BDAT_LAST {
 				copy := model.NewEmptyMailItem(w.logger)
 				copier.Copy(copy, mailItem)
+				copy.AuthUser = w.session.AuthenticatedUser
+				copy.HeloDomain = w.session.HeloDomain
 				w.Receiver <- copy
 
 				mailItem = model.NewEmptyMailItem(w.logger)
@@ -217,16 +317,21 @@ func (w *Worker) getExecutorFromCommand(command Command) mailslurper.ICommandExe
 			w.logger.With("who", "MAIL Command Executor"),
 			w.Reader,
 			w.Writer,
+			w.session,
 			w.EmailValidationService,
 			w.XSSService,
+			w.config.MaxMessageSize,
 		)
 	case RCPT:
 		return NewRcptCommandExecutor(
 			w.logger.With("who", "RCPT TO Command Executor"),
 			w.Reader,
 			w.Writer,
+			w.session,
 			w.EmailValidationService,
 			w.XSSService,
+			w.faults,
+			w.greylist,
 		)
 	case DATA:
 		return NewDataCommandExecutor(
@@ -235,6 +340,19 @@ func (w *Worker) getExecutorFromCommand(command Command) mailslurper.ICommandExe
 			w.Writer,
 			w.EmailValidationService,
 			w.XSSService,
+			w.config.MaxMessageSize,
+			w.faults,
+		)
+	case BDAT, BDAT_LAST:
+		return NewBdatCommandExecutor(
+			w.logger.With("who", "BDAT Command Executor"),
+			w.Reader,
+			w.Writer,
+			w.session,
+			w.EmailValidationService,
+			w.XSSService,
+			w.config.MaxMessageSize,
+			w.faults,
 		)
 	case RSET:
 		return NewResetCommandExecutor(
@@ -246,15 +364,61 @@ func (w *Worker) getExecutorFromCommand(command Command) mailslurper.ICommandExe
 			w.logger.With("who", "NOOP Command Executor"),
 			w.Writer,
 		)
+	case STARTTLS:
+		return NewStartTLSCommandExecutor(
+			w.logger.With("who", "STARTTLS Command Executor"),
+			w.Reader,
+			w.Writer,
+			w.session,
+			w.tlsConfig,
+		)
+	case AUTH:
+		return NewAuthCommandExecutor(
+			w.logger.With("who", "AUTH Command Executor"),
+			w.Reader,
+			w.Writer,
+			w.session,
+			NewCredentialValidator(w.config.Auth),
+		)
 	default:
 		return NewHelloCommandExecutor(
 			w.logger.With("who", "HELO Command Executor"),
 			w.Reader,
 			w.Writer,
+			w.session,
+			w.extensions(),
 		)
 	}
 }
 
+// extensions returns the service extensions to advertise in response to EHLO given the current connection state.
+// SIZE is advertised without a value when there is no fixed maximum message size (RFC 1870).
+func (w *Worker) extensions() []string {
+	size := SMTP_EXTENSION_SIZE
+	if w.config.MaxMessageSize > 0 {
+		size += " " + strconv.FormatInt(w.config.MaxMessageSize, 10)
+	}
+
+	result := []string{
+		size,
+		SMTP_EXTENSION_8BITMIME,
+		SMTP_EXTENSION_PIPELINING,
+		SMTP_EXTENSION_CHUNKING,
+		SMTP_EXTENSION_SMTPUTF8,
+		SMTP_EXTENSION_ENHANCEDSTATUSCODES,
+	}
+
+	if w.tlsConfig != nil && !w.Reader.IsTLS() {
+		result = append(result, SMTP_EXTENSION_STARTTLS)
+	}
+
+	if w.config.Auth.Mode.IsEnabled() {
+		result = append(result, SMTP_EXTENSION_AUTH+" "+strings.Join(AuthMechanisms, " "))
+	}
+
+	return result
+}
+
 // TimeoutHasExpired determines if the time elapsed since a start time has exceeded the command timeout.
 func (w *Worker) TimeoutHasExpired(startTime time.Time) bool {
 	return int(time.Since(startTime).Seconds()) > COMMAND_TIMEOUT_SECONDS
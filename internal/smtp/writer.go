@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -62,6 +63,33 @@ func (w *Writer) SendHELOResponse() error {
 	return w.SendResponse(SMTP_HELLO_RESPONSE_MESSAGE)
 }
 
+// SendEHLOResponse sends the reply to EHLO, which is the greeting followed by one line for each supported service
+// extension.
+func (w *Writer) SendEHLOResponse(extensions []string) error {
+	return w.SendMultilineResponse(SMTP_REPLY_OK, append([]string{SMTP_HELLO_GREETING}, extensions...)...)
+}
+
+// SendMultilineResponse sends a reply made up of one or more lines sharing the same reply code. Every line but the
+// last separates the code from the text with a hyphen (RFC 5321, section 4.2.1).
+func (w *Writer) SendMultilineResponse(code int, lines ...string) error {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	reply := make([]string, len(lines))
+
+	for idx, line := range lines {
+		separator := "-"
+		if idx == len(lines)-1 {
+			separator = " "
+		}
+
+		reply[idx] = strconv.Itoa(code) + separator + line
+	}
+
+	return w.SendResponse(strings.Join(reply, SMTP_CRLF))
+}
+
 // SendOkResponse sends an OK to a client.
 func (w *Writer) SendOkResponse() error {
 	return w.SendResponse(SMTP_OK_MESSAGE)
@@ -0,0 +1,96 @@
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// purgeInterval is how often expired items are removed while items are being set.
+const purgeInterval = time.Minute
+
+type memoryCacheItem struct {
+	value   interface{}
+	expires time.Time
+}
+
+/*
+MemoryCacheService is an ICacheService that keeps items in memory. Expired
+items are never returned, and are removed from time to time as new items
+are set.
+*/
+type MemoryCacheService struct {
+	lock      sync.Mutex
+	items     map[string]memoryCacheItem
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+/*
+NewMemoryCacheService creates a new, empty in-memory cache
+*/
+func NewMemoryCacheService() *MemoryCacheService {
+	return &MemoryCacheService{
+		items:     make(map[string]memoryCacheItem),
+		lastPurge: time.Now(),
+		now:       time.Now,
+	}
+}
+
+/*
+Delete removes an item from the cache
+*/
+func (c *MemoryCacheService) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.items, key)
+}
+
+/*
+Get returns an item from the cache, and false if it does not exist or has
+expired
+*/
+func (c *MemoryCacheService) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.now().Before(item.expires) {
+		delete(c.items, key)
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+/*
+Set stores an item in the cache until the timeout has passed
+*/
+func (c *MemoryCacheService) Set(key string, value interface{}, timeout time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+
+	if now.Sub(c.lastPurge) >= purgeInterval {
+		for itemKey, item := range c.items {
+			if !now.Before(item.expires) {
+				delete(c.items, itemKey)
+			}
+		}
+
+		c.lastPurge = now
+	}
+
+	c.items[key] = memoryCacheItem{
+		value:   value,
+		expires: now.Add(timeout),
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheService(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	service := NewMemoryCacheService()
+	service.now = func() time.Time { return now }
+
+	service.Set("one", 1, time.Minute)
+	service.Set("two", 2, 2*time.Minute)
+
+	value, ok := service.Get("one")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	service.Delete("one")
+
+	_, ok = service.Get("one")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+
+	_, ok = service.Get("two")
+	assert.False(t, ok, "expired items should not be returned")
+
+	// setting an item after the purge interval removes everything that has expired
+	service.Set("three", 3, 0)
+	service.Set("four", 4, time.Minute)
+	now = now.Add(purgeInterval)
+	service.Set("five", 5, time.Minute)
+
+	assert.Len(t, service.items, 1)
+}
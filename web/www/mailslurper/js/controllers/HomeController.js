@@ -156,6 +156,34 @@
 			var url = window.MailService.getMailMessageURL(serviceURL, id);
 			window.open(url);
 		});
+
+		$("#mailDetails").on("click", "#loadRemoteImages", function (e) {
+			e.preventDefault();
+
+			var id = $(this).attr("data-id");
+
+			loadMailPreview(id, true);
+			$(this).remove();
+		});
+	};
+
+	/*
+	 * Loads the HTML preview of a mail item into the sandboxed frame. The
+	 * preview is fetched with the API token and handed to the frame as
+	 * srcdoc, as a frame cannot send the token when loading a URL itself.
+	 */
+	function loadMailPreview(id, remoteImages) {
+		window.MailService.getMailPreview(serviceURL, id, remoteImages)
+			.then(function (html) {
+				$("#mailPreview").attr("srcdoc", html);
+			})
+			.catch(function (err) {
+				if (window.AuthService.isUnauthorized(err)) {
+					window.AuthService.gotoLogin();
+				}
+
+				window.AlertService.error("There was a problem getting this mail's preview");
+			});
 	};
 
 	/*
@@ -247,6 +275,38 @@
 		return performSearch();
 	};
 
+	/**
+	 * Refreshes the mail list shortly after a mail event, so a burst of
+	 * events only refreshes it once.
+	 */
+	function scheduleRefresh() {
+		if (pendingRefresh !== null) {
+			return;
+		}
+
+		pendingRefresh = window.setTimeout(function () {
+			pendingRefresh = null;
+			refreshMailList();
+		}, 500);
+	};
+
+	/**
+	 * Follows the mail events pushed by the server, refreshing the mail
+	 * list as soon as mail is received or deleted. Servers running without
+	 * the SMTP server in the same process have no events to follow.
+	 */
+	function subscribeToMailEvents() {
+		if (!window.EventSource) {
+			return;
+		}
+
+		var source = new EventSource(window.MailService.getMailEventsURL(serviceURL));
+
+		source.addEventListener("mail.received", scheduleRefresh);
+		source.addEventListener("mail.deleted", scheduleRefresh);
+		source.addEventListener("mail.pruned", scheduleRefresh);
+	};
+
 	/**
 	 * Removes highlights from all mail rows
 	 */
@@ -291,6 +351,8 @@
 		var html = mailDetailsTemplate({ mail: mail });
 		$("#mailDetails").html(html);
 		$("#openInTab").attr("data-id", mail.id);
+
+		loadMailPreview(mail.id, false);
 	};
 
 	/**
@@ -508,6 +570,7 @@
 	var totalPages = 0;
 	var totalMailCount = 0;
 	var page = 1;
+	var pendingRefresh = null;
 	var searchCriteria = {
 		searchMessage: "",
 		searchStart: moment().startOf("month"),
@@ -533,6 +596,7 @@
 		loadSearchMailModalTemplate()
 	])
 		.then(performSearch)
+		.then(subscribeToMailEvents)
 		.catch(function (err) {
 			if (window.AuthService.isUnauthorized(err)) {
 				window.AuthService.gotoLogin();
@@ -91,6 +91,19 @@ window.MailService = {
 		});
 	},
 
+	/**
+	 * getMailEventsURL returns the full service URL of the stream of mail
+	 * events, for use with an EventSource. An EventSource cannot send the
+	 * Authorization header, so the API token goes in the URL.
+	 */
+	getMailEventsURL: function (serviceURL) {
+		if (window.AuthService.tokenExistsInStorage()) {
+			return serviceURL + "/events?token=" + encodeURIComponent(window.AuthService.getToken());
+		}
+
+		return serviceURL + "/events";
+	},
+
 	/**
 	 * getMailMessageURL returns the full service URL to get a mail's message body
 	 */
@@ -98,6 +111,27 @@ window.MailService = {
 		return serviceURL + "/mail/" + mailID + "/message";
 	},
 
+	/**
+	 * getMailPreview returns a mail's HTML for showing in a sandboxed frame.
+	 * Remote images are blocked unless remoteImages is true.
+	 */
+	getMailPreview: function (serviceURL, mailID, remoteImages) {
+		return new Promise(function (resolve, reject) {
+			$.ajax(window.AuthService.decorateRequestWithAuthorization({
+				method: "GET",
+				url: serviceURL + "/mail/" + mailID + "/preview?remoteImages=" + (remoteImages ? "true" : "false"),
+				dataType: "html"
+			})).then(
+				function (result) {
+					return resolve(result);
+				},
+				function (xhr, errorType, err) {
+					return reject(err);
+				}
+			);
+		});
+	},
+
 	/**
 	 * getMails returns a page of stored email. The page number must be a key
 	 * named "page" in the context object. This will return mail items as an
@@ -11,7 +11,7 @@ Handlebars.registerHelper("ifIsImageAttachment", function (attachment, block) {
 		"image/gif"
 	];
 
-	if (validImageMIMETypes.indexOf(attachment.headers.contentType) > -1) {
+	if (validImageMIMETypes.indexOf(attachment.contentType) > -1) {
 		return block.fn(this);
 	} else {
 		return block.inverse(this);